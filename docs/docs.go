@@ -17,19 +17,561 @@ const docTemplate = `{
     "paths": {
         "/task/new": {
             "get": {
-                "description": "Создаёт новую задачу для добавления файловых ссылок и последующего создания ZIP-архива.",
+                "description": "Создаёт новую задачу для добавления файловых ссылок и последующего создания архива.\nPOST принимает необязательное тело с callback_url: по завершении задачи (Done или Error) на него отправляется подписанный POST с телом как у /task/{id}/status.\nФормат архива (zip, tar, tar.gz, tar.zst) задаётся полем format тела или параметром запроса format, по умолчанию zip.\nПоле password тела шифрует файлы zip-архива AES-256 (WinZip AE-2, открывается 7-Zip и Windows). Пароль не логируется и не сохраняется на диск: незавершённая задача с паролем после перезапуска сервиса завершается ошибкой.\nПоле manifest тела или параметр запроса manifest=true добавляет в архив manifest.json (источник, итоговый URL после редиректов, Content-Type, размер, SHA-256 и время загрузки каждого объекта, а также ошибки незагруженных объектов) и SHA256SUMS.\nПоле name_conflict тела или параметр запроса name_conflict задаёт обработку совпадающих имён объектов, добавленных с name: suffix (по умолчанию), overwrite или fail.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Создать новую задачу архивации",
+                "parameters": [
+                    {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива: zip, tar, tar.gz, tar.zst",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить в архив manifest.json и SHA256SUMS",
+                        "name": "manifest",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "suffix",
+                            "overwrite",
+                            "fail"
+                        ],
+                        "type": "string",
+                        "description": "Совпадение имён объектов: suffix, overwrite, fail",
+                        "name": "name_conflict",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Необязательные параметры задачи (только POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/new_task.Request"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Задача успешно создана",
+                        "schema": {
+                            "$ref": "#/definitions/new_task.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Пароль задан не для zip-архива ('password is supported only for zip archives')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Превышен лимит одновременно выполняемых задач",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Создаёт новую задачу для добавления файловых ссылок и последующего создания архива.\nPOST принимает необязательное тело с callback_url: по завершении задачи (Done или Error) на него отправляется подписанный POST с телом как у /task/{id}/status.\nФормат архива (zip, tar, tar.gz, tar.zst) задаётся полем format тела или параметром запроса format, по умолчанию zip.\nПоле password тела шифрует файлы zip-архива AES-256 (WinZip AE-2, открывается 7-Zip и Windows). Пароль не логируется и не сохраняется на диск: незавершённая задача с паролем после перезапуска сервиса завершается ошибкой.\nПоле manifest тела или параметр запроса manifest=true добавляет в архив manifest.json (источник, итоговый URL после редиректов, Content-Type, размер, SHA-256 и время загрузки каждого объекта, а также ошибки незагруженных объектов) и SHA256SUMS.\nПоле name_conflict тела или параметр запроса name_conflict задаёт обработку совпадающих имён объектов, добавленных с name: suffix (по умолчанию), overwrite или fail.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Создать новую задачу архивации",
+                "parameters": [
+                    {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива: zip, tar, tar.gz, tar.zst",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Добавить в архив manifest.json и SHA256SUMS",
+                        "name": "manifest",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "suffix",
+                            "overwrite",
+                            "fail"
+                        ],
+                        "type": "string",
+                        "description": "Совпадение имён объектов: suffix, overwrite, fail",
+                        "name": "name_conflict",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Необязательные параметры задачи (только POST)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/new_task.Request"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Задача успешно создана",
+                        "schema": {
+                            "$ref": "#/definitions/new_task.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Пароль задан не для zip-архива ('password is supported only for zip archives')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Превышен лимит одновременно выполняемых задач",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}": {
+            "delete": {
+                "description": "Удаляет задачу вместе с её архивом. Задача, находящаяся в работе, предварительно отменяется.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Удалить задачу архивации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Задача удалена"
+                    },
+                    "400": {
+                        "description": "Параметр taskID отсутствует",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/add": {
+            "post": {
+                "description": "Добавляет один или несколько файловых URL в существующую задачу архивации.\nURL с неподдерживаемым расширением или доменом вне списка разрешённых (archiver.allowed_domains, archiver.blocked_domains) не добавляется, ошибка возвращается для каждого URL.\nКроме http(s) поддерживаются схемы data: (RFC 2397), file:// (только каталоги archive_object_getter.file.roots), ftp:// и s3://bucket/key, если они настроены. URL с неподключённой схемой даёт ошибку \"unsupported scheme\", файл вне разрешённых каталогов или бакет вне разрешённых — \"source not allowed\".\nЭлемент urls — строка с URL или объект {url, name, path}: name задаёт имя файла в архиве, path — папку внутри архива.\nДля защищённых источников (http(s) и ftp) объект может содержать headers — заголовки запроса (Authorization, Cookie, X-Api-Key и т.п.) и/или credential — имя учётных данных из конфигурации (archiver.archive_object_getter.credentials).\nЗаголовки отправляются только на хост из URL объекта, не на хосты редиректов; они не пишутся в логи, не сохраняются на диск и не возвращаются в get_status, поэтому незавершённая задача с заголовками после перезапуска сервиса завершается ошибкой.\nНекорректные или служебные заголовки (Host, Content-Length, Range, Accept-Encoding и т.п.) дают ошибку URL \"invalid headers\", неизвестные учётные данные или учётные данные не для домена URL — \"credential not allowed\".\nБез name имя берётся из URL с префиксом-номером объекта. Имена и папки с \"..\", \":\", обратной косой чертой или управляющими символами, а также абсолютные пути отклоняются.\nСовпадение имени с уже добавленным объектом обрабатывается по политике name_conflict задачи: suffix (file (1).pdf), overwrite (замена объекта) или fail (409).\nНеобязательный callback_url заменяет URL, на который будет отправлено уведомление о завершении задачи; он регистрируется до добавления объектов.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Добавить объекты в задачу архивации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Список URL-адресов для добавления",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/add_objects.Request"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ссылки успешно добавлены в задачу",
+                        "schema": {
+                            "$ref": "#/definitions/add_objects.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Задача отменена ('Task is canceled')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Имя уже занято при политике fail ('object name already taken')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Задача истекла ('Task expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/cancel": {
+            "post": {
+                "description": "Отменяет задачу, ожидающую объекты или находящуюся в архивации. Загрузки прерываются, слот задачи освобождается, частично записанный архив удаляется.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Отменить задачу архивации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Задача отменена",
+                        "schema": {
+                            "$ref": "#/definitions/cancel_task.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Задача уже отменена ('Task is canceled')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Задача истекла ('Task expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/events": {
+            "get": {
+                "description": "Открывает поток Server-Sent Events с прогрессом задачи. Первым приходит событие \"status\" с текущим состоянием задачи (как в /task/{id}/status),\nзатем события: object_added, download_started, download_finished, download_failed, archive_written, task_done, task_error, task_canceled, task_expired.\nПоток закрывается после task_done, task_error, task_canceled, task_expired, удаления задачи или остановки сервиса. Если задача уже завершена, приходит только \"status\".",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Поток событий задачи (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Поток событий",
+                        "schema": {
+                            "$ref": "#/definitions/task_events.Event"
+                        }
+                    },
+                    "400": {
+                        "description": "Параметр taskID отсутствует",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/link": {
+            "post": {
+                "description": "Выпускает новую подписанную ссылку на архив завершённой задачи. Новая ссылка также возвращается в статусе задачи.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Получить новую ссылку на архив",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Время жизни ссылки, например 1h30m. По умолчанию — из конфига хранилища",
+                        "name": "ttl",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Новая ссылка на архив",
+                        "schema": {
+                            "$ref": "#/definitions/new_link.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "У задачи нет архива ('Task has no archive')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Архив удалён по политике хранения ('Archive expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/start": {
+            "post": {
+                "description": "Запускает архивацию задачи, не дожидаясь заполнения до max_objects. В задаче должен быть хотя бы один объект.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Запустить архивацию задачи",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Архивация запущена",
+                        "schema": {
+                            "$ref": "#/definitions/start_task.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Задача отменена ('Task is canceled')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена ('Task not found')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Задача истекла ('Task expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Сервис архивации остановлен",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/task/{id}/status": {
+            "get": {
+                "description": "Возвращает текущий статус задачи архивации, список объектов, ошибки и ссылку на архив (если задача завершена). Если архив удалён по политике хранения, статус — \"Archive expired\", ссылка не возвращается. Для архивов с паролем возвращается \"encrypted\": true.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Создать новую задачу архивации",
+                "summary": "Получить статус задачи архивации",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
-                        "description": "Задача успешно создана",
+                        "description": "Информация о задаче",
                         "schema": {
-                            "$ref": "#/definitions/new_task.Response"
+                            "$ref": "#/definitions/get_status.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Параметр taskID отсутствует",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
                         }
                     },
                     "500": {
@@ -39,7 +581,7 @@ const docTemplate = `{
                         }
                     },
                     "503": {
-                        "description": "Превышен лимит одновременно выполняемых задач",
+                        "description": "Сервис архивации остановлен",
                         "schema": {
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
@@ -47,11 +589,11 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/{id}/add": {
+        "/task/{id}/upload": {
             "post": {
-                "description": "Добавляет один или несколько файловых URL в существующую задачу архивации.",
+                "description": "Принимает файлы в multipart/form-data и добавляет их в задачу наравне с объектами по URL.\nКаждая часть с именем файла (filename) становится объектом задачи, имя файла — именем записи в архиве. Поле path задаёт папку внутри архива для следующих за ним файлов.\nК файлам применяются те же правила, что и к URL: расширение проверяется по archiver.valid_extension, тип, определённый по содержимому файла, — по archive_object_getter.valid_content_type, размер — по archive_object_getter.max_object_size; файлы учитываются в max_objects.\nРазмер всего запроса ограничен archiver.upload.max_request_size (по умолчанию 1 ГиБ). Файлы пишутся во временный каталог archiver.upload.dir, а не в память, и удаляются после завершения, отмены или истечения задачи.\nСовпадение имени с уже добавленным объектом обрабатывается по политике name_conflict задачи.",
                 "consumes": [
-                    "application/json"
+                    "multipart/form-data"
                 ],
                 "produces": [
                     "application/json"
@@ -59,7 +601,7 @@ const docTemplate = `{
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Добавить объекты в задачу архивации",
+                "summary": "Загрузить файлы в задачу архивации",
                 "parameters": [
                     {
                         "type": "string",
@@ -69,24 +611,28 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "Список URL-адресов для добавления",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/add_objects.Request"
-                        }
+                        "type": "string",
+                        "description": "Папка внутри архива для следующих файлов",
+                        "name": "path",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "file",
+                        "description": "Файл, может повторяться",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Ссылки успешно добавлены в задачу",
+                        "description": "Файлы добавлены в задачу",
                         "schema": {
-                            "$ref": "#/definitions/add_objects.Response"
+                            "$ref": "#/definitions/upload_objects.Response"
                         }
                     },
                     "400": {
-                        "description": "Задача уже завершена ('Task is completed')",
+                        "description": "Задача отменена ('Task is canceled')",
                         "schema": {
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
@@ -97,6 +643,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Имя уже занято при политике fail ('object name already taken')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Задача истекла ('Task expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Запрос больше upload.max_request_size ('request body too large')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Внутренняя ошибка сервера",
                         "schema": {
@@ -112,16 +676,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/task/{id}/status": {
+        "/task/{id}/webhooks": {
             "get": {
-                "description": "Возвращает текущий статус задачи архивации, список объектов, ошибки и ссылку на архив (если задача завершена).",
+                "description": "Возвращает попытки доставки уведомлений о завершении задачи на callback_url, от старых к новым.\nЖурнал хранится в памяти и не переживает перезапуск сервиса.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Получить статус задачи архивации",
+                "summary": "Журнал доставки webhook-уведомлений задачи",
                 "parameters": [
                     {
                         "type": "string",
@@ -133,9 +697,9 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Информация о задаче",
+                        "description": "Журнал доставки",
                         "schema": {
-                            "$ref": "#/definitions/get_status.Response"
+                            "$ref": "#/definitions/task_webhooks.Response"
                         }
                     },
                     "400": {
@@ -145,7 +709,7 @@ const docTemplate = `{
                         }
                     },
                     "404": {
-                        "description": "Задача не найдена",
+                        "description": "Задача не найдена ('Task not found')",
                         "schema": {
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
@@ -167,48 +731,118 @@ const docTemplate = `{
         },
         "/zips/{filename}": {
             "get": {
-                "description": "Возвращает готовый ZIP-архив задачи по имени файла. Если файл не найден — возвращает ошибку.",
+                "description": "Возвращает готовый архив задачи по имени файла, Content-Type соответствует формату (zip, tar, tar.gz, tar.zst). Если файл не найден — возвращает ошибку. Поддерживаются Range-запросы.\nЕсли задан local_zip_storage.link_secret, ссылка должна содержать действительную подпись и не истёкший срок действия.",
                 "produces": [
-                    "application/zip"
+                    "application/zip",
+                    "application/x-tar",
+                    "application/gzip",
+                    "application/zstd"
                 ],
                 "tags": [
                     "zips"
                 ],
-                "summary": "Скачать готовый ZIP-архив",
+                "summary": "Скачать готовый архив",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Имя ZIP-файла",
+                        "description": "Имя файла архива",
                         "name": "filename",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Срок действия ссылки, Unix-время",
+                        "name": "expires",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "HMAC-SHA256 подпись имени файла и срока действия",
+                        "name": "signature",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "ZIP-архив для скачивания",
+                        "description": "Архив для скачивания",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Запрошенная часть архива",
                         "schema": {
                             "type": "file"
                         }
                     },
+                    "403": {
+                        "description": "Неверная подпись ссылки ('Invalid link signature')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Файл не найден",
                         "schema": {
                             "$ref": "#/definitions/response.ErrorResponse"
                         }
+                    },
+                    "410": {
+                        "description": "Срок действия ссылки истёк ('Link expired')",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Внутренняя ошибка сервера",
+                        "schema": {
+                            "$ref": "#/definitions/response.ErrorResponse"
+                        }
                     }
                 }
             }
         }
     },
     "definitions": {
+        "add_objects.Item": {
+            "type": "object",
+            "properties": {
+                "credential": {
+                    "description": "Credential is the name of a credential from archiver.archive_object_getter.credentials",
+                    "type": "string"
+                },
+                "headers": {
+                    "description": "Headers are sent with the request for the object, e.g. Authorization or Cookie.\nThey are not logged, persisted or returned by get_status.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "Name of the entry inside the archive, by default it is taken from the URL",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Path is the directory of the entry inside the archive",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
         "add_objects.Request": {
             "type": "object",
             "properties": {
+                "callback_url": {
+                    "description": "CallbackURL replaces the callback URL of the task if set",
+                    "type": "string"
+                },
                 "urls": {
                     "type": "array",
                     "items": {
-                        "type": "string"
+                        "$ref": "#/definitions/add_objects.Item"
                     }
                 }
             }
@@ -219,6 +853,10 @@ const docTemplate = `{
                 "added": {
                     "type": "integer"
                 },
+                "replaced": {
+                    "description": "Replaced is the number of the earlier objects replaced under the overwrite name conflict policy",
+                    "type": "integer"
+                },
                 "urls": {
                     "type": "array",
                     "items": {
@@ -238,12 +876,29 @@ const docTemplate = `{
                 }
             }
         },
+        "cancel_task.Response": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
         "get_status.Objects": {
             "type": "object",
             "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
                 "error": {
                     "type": "string"
                 },
+                "name": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
                 "src": {
                     "type": "string"
                 }
@@ -252,9 +907,16 @@ const docTemplate = `{
         "get_status.Response": {
             "type": "object",
             "properties": {
+                "encrypted": {
+                    "description": "Encrypted is set for archives protected with a password",
+                    "type": "boolean"
+                },
                 "error": {
                     "type": "string"
                 },
+                "format": {
+                    "type": "string"
+                },
                 "objects": {
                     "type": "array",
                     "items": {
@@ -269,6 +931,37 @@ const docTemplate = `{
                 }
             }
         },
+        "new_link.Response": {
+            "type": "object",
+            "properties": {
+                "zip": {
+                    "type": "string"
+                }
+            }
+        },
+        "new_task.Request": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "type": "string"
+                },
+                "format": {
+                    "type": "string"
+                },
+                "manifest": {
+                    "description": "Manifest adds manifest.json and SHA256SUMS to the archive",
+                    "type": "boolean"
+                },
+                "name_conflict": {
+                    "description": "NameConflict is the policy for the objects named by the client: suffix, overwrite or fail",
+                    "type": "string"
+                },
+                "password": {
+                    "description": "Password encrypts zip entries with AES-256, accepted only in the body",
+                    "type": "string"
+                }
+            }
+        },
         "new_task.Response": {
             "type": "object",
             "properties": {
@@ -284,6 +977,107 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "start_task.Response": {
+            "type": "object",
+            "properties": {
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "task_events.Event": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "object": {
+                    "description": "Object is the index of the object in the task, absent for the events of the whole task",
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "src": {
+                    "type": "string"
+                },
+                "zip": {
+                    "type": "string"
+                }
+            }
+        },
+        "task_webhooks.Delivery": {
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status_code": {
+                    "type": "integer"
+                },
+                "task_status": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "task_webhooks.Response": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "type": "string"
+                },
+                "deliveries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/task_webhooks.Delivery"
+                    }
+                }
+            }
+        },
+        "upload_objects.File": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "upload_objects.Response": {
+            "type": "object",
+            "properties": {
+                "added": {
+                    "type": "integer"
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/upload_objects.File"
+                    }
+                },
+                "replaced": {
+                    "description": "Replaced is the number of the earlier objects replaced under the overwrite name conflict policy",
+                    "type": "integer"
+                }
+            }
         }
     }
 }`
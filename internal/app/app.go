@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/fandasy/06.08.2025/internal/config"
 	zips_download "github.com/fandasy/06.08.2025/internal/http/handlers/zips-download"
 	swaggerFiles "github.com/swaggo/files"
@@ -10,27 +11,50 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	add_objects "github.com/fandasy/06.08.2025/internal/http/handlers/add-objects"
+	cancel_task "github.com/fandasy/06.08.2025/internal/http/handlers/cancel-task"
+	delete_task "github.com/fandasy/06.08.2025/internal/http/handlers/delete-task"
 	get_status "github.com/fandasy/06.08.2025/internal/http/handlers/get-status"
+	new_link "github.com/fandasy/06.08.2025/internal/http/handlers/new-link"
 	new_task "github.com/fandasy/06.08.2025/internal/http/handlers/new-task"
+	start_task "github.com/fandasy/06.08.2025/internal/http/handlers/start-task"
+	task_events "github.com/fandasy/06.08.2025/internal/http/handlers/task-events"
+	task_webhooks "github.com/fandasy/06.08.2025/internal/http/handlers/task-webhooks"
+	upload_objects "github.com/fandasy/06.08.2025/internal/http/handlers/upload-objects"
+	"github.com/fandasy/06.08.2025/internal/http/webhook"
 
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/cors"
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
 
 	"github.com/fandasy/06.08.2025/internal/models"
 
+	local_storage "github.com/fandasy/06.08.2025/internal/object-storage/local-storage"
 	local_zip_storage "github.com/fandasy/06.08.2025/internal/object-storage/local-zip-storage"
+	s3_zip_storage "github.com/fandasy/06.08.2025/internal/object-storage/s3-zip-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	http_client "github.com/fandasy/06.08.2025/internal/pkg/http-client"
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
+	signed_link "github.com/fandasy/06.08.2025/internal/pkg/signed-link"
 	"github.com/fandasy/06.08.2025/internal/services/archiver"
 	"github.com/fandasy/06.08.2025/internal/services/archiver/utils"
+	file_task_store "github.com/fandasy/06.08.2025/internal/task-store/file-task-store"
 
 	_ "github.com/fandasy/06.08.2025/docs"
 	"github.com/gin-gonic/gin"
 )
 
+// defaultMaxUploadSize limits an upload request if archiver.upload.max_request_size is not set
+const defaultMaxUploadSize = 1 << 30
+
 type App struct {
-	server   *http.Server
-	archiver archiver.Archiver
+	server     *http.Server
+	archiver   archiver.Archiver
+	taskStore  *file_task_store.Store
+	dispatcher *webhook.Dispatcher
 }
 
 // @title           ZIP Archiver API
@@ -39,23 +63,239 @@ type App struct {
 func New(env string, cfg *config.Config, log *slog.Logger) (*App, error) {
 	log.Debug("Config", slog.String("env", env), slog.Any("cfg", cfg))
 
-	archiveObjectGetter := utils.NewArchiveObjectGetter(http.DefaultClient, cfg.Archiver.ArchiveObjectGetter.ValidContentType)
+	domains, err := domain_policy.New(cfg.Archiver.AllowedDomains, cfg.Archiver.BlockedDomains)
+	if err != nil {
+		return nil, err
+	}
+
+	getterCfg := utils.Config{
+		ValidContentTypes: cfg.Archiver.ArchiveObjectGetter.ValidContentType,
+		MaxObjectSize:     cfg.Archiver.ArchiveObjectGetter.MaxObjectSize,
+		Domains:           domains,
+		Hosts: utils.HostLimits{
+			MaxConns:          cfg.Archiver.ArchiveObjectGetter.MaxConnsPerHost,
+			RequestsPerSecond: cfg.Archiver.ArchiveObjectGetter.RequestsPerSecond,
+		},
+	}
+
+	if retry := cfg.Archiver.ArchiveObjectGetter.Retry; retry != nil {
+		getterCfg.Retry = utils.RetryPolicy{
+			MaxAttempts:    retry.MaxAttempts,
+			InitialBackoff: retry.InitialBackoff,
+			MaxBackoff:     retry.MaxBackoff,
+		}
+	}
+
+	// Objects and webhooks are fetched from client URLs, they can't reach internal addresses
+	var dialerCfg safe_dialer.Config
+	if ssrf := cfg.SSRFProtection; ssrf != nil {
+		dialerCfg = safe_dialer.Config{
+			Allow: ssrf.Allow,
+			Deny:  ssrf.Deny,
+		}
+	}
+
+	dialer, err := safe_dialer.New(dialerCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var clientCfg http_client.Config
+	if h := cfg.Archiver.ArchiveObjectGetter.HTTP; h != nil {
+		clientCfg = http_client.Config{
+			ConnectTimeout:        h.ConnectTimeout,
+			TLSHandshakeTimeout:   h.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: h.ResponseHeaderTimeout,
+			Timeout:               h.Timeout,
+			Proxy:                 h.Proxy,
+			UserAgent:             h.UserAgent,
+			Headers:               h.Headers,
+			CAFile:                h.CAFile,
+			MaxRedirects:          h.MaxRedirects,
+			MaxIdleConns:          h.MaxIdleConns,
+			MaxIdleConnsPerHost:   h.MaxIdleConnsPerHost,
+			IdleConnTimeout:       h.IdleConnTimeout,
+			DisableKeepAlives:     h.DisableKeepAlives,
+		}
+	}
+
+	creds := make(map[string]credentials.Credential, len(cfg.Archiver.ArchiveObjectGetter.Credentials))
+	for name, c := range cfg.Archiver.ArchiveObjectGetter.Credentials {
+		headers := make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			headers[k] = string(v)
+		}
+
+		creds[name] = credentials.Credential{Domains: c.Domains, Headers: headers}
+	}
+
+	credentialStore, err := credentials.New(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	getterCfg.Credentials = credentialStore
+
+	getterClient, err := http_client.New(clientCfg, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uploaded files are spooled to disk until their task is finished
+	uploadDir := filepath.Join(os.TempDir(), "zip-archiver-uploads")
+	if cfg.Archiver.Upload != nil && cfg.Archiver.Upload.Dir != "" {
+		uploadDir = cfg.Archiver.Upload.Dir
+	}
+
+	var maxUploadSize int64 = defaultMaxUploadSize
+	if cfg.Archiver.Upload != nil && cfg.Archiver.Upload.MaxRequestSize > 0 {
+		maxUploadSize = cfg.Archiver.Upload.MaxRequestSize
+	}
 
-	zipsDownloadMethodPath := url.URL{
-		Scheme: "http",
-		Host:   cfg.HttpServer.Addr,
-		Path:   "zips",
+	uploadStorage, err := local_storage.New(uploadDir)
+	if err != nil {
+		return nil, err
 	}
 
-	localZipStorage, err := local_zip_storage.New(zipsDownloadMethodPath.String(), cfg.LocalZipStorage.Dir)
+	uploads := utils.NewUploads(uploadStorage, getterCfg)
+
+	archiveObjectGetter, err := newSchemes(cfg.Archiver.ArchiveObjectGetter, getterClient, clientCfg, dialer, uploads, getterCfg)
 	if err != nil {
 		return nil, err
 	}
 
-	Archiver := archiver.New(archiver.Config{
-		MaxTasks:   cfg.Archiver.MaxTasks,
-		MaxObjects: cfg.Archiver.MaxObjects,
-	}, archiveObjectGetter, localZipStorage, log)
+	var archiveSaver archiver.ArchiveSaver
+	var archiveRetention archiver.ArchiveRetention
+	var zipLinks *signed_link.Signer
+
+	switch cfg.ArchiveStorage {
+	case config.ArchiveStorageLocal, "":
+		zipsDownloadMethodPath := url.URL{
+			Scheme: "http",
+			Host:   cfg.HttpServer.Addr,
+			Path:   "zips",
+		}
+
+		if cfg.LocalZipStorage.LinkSecret != "" {
+			zipLinks = signed_link.New(string(cfg.LocalZipStorage.LinkSecret), cfg.LocalZipStorage.LinkTTL)
+		} else {
+			log.Warn("Zip link secret is not set, archive links are not signed")
+		}
+
+		localZipStorage, err := local_zip_storage.New(zipsDownloadMethodPath.String(), cfg.LocalZipStorage.Dir, zipLinks)
+		if err != nil {
+			return nil, err
+		}
+
+		archiveSaver = localZipStorage
+
+		if r := cfg.LocalZipStorage.Retention; r != nil {
+			archiveRetention = archiver.ArchiveRetention{
+				MaxAge:       r.MaxAge,
+				MaxTotalSize: r.MaxTotalSize,
+				MaxCount:     r.MaxCount,
+			}
+		}
+
+	case config.ArchiveStorageS3:
+		if cfg.S3ZipStorage == nil {
+			return nil, errors.New("s3_zip_storage config is missing")
+		}
+
+		s3Cfg := s3_zip_storage.Config{
+			Endpoint:   cfg.S3ZipStorage.Endpoint,
+			Region:     cfg.S3ZipStorage.Region,
+			Bucket:     cfg.S3ZipStorage.Bucket,
+			AccessKey:  cfg.S3ZipStorage.AccessKey,
+			SecretKey:  string(cfg.S3ZipStorage.SecretKey),
+			PathStyle:  cfg.S3ZipStorage.PathStyle,
+			Prefix:     cfg.S3ZipStorage.Prefix,
+			PartSize:   cfg.S3ZipStorage.PartSize,
+			LinkExpiry: cfg.S3ZipStorage.LinkExpiry,
+			Timeout:    cfg.S3ZipStorage.Timeout,
+		}
+
+		// Credentials are better kept out of the config file
+		if s3Cfg.AccessKey == "" {
+			s3Cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if s3Cfg.SecretKey == "" {
+			s3Cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+
+		s3ZipStorage, err := s3_zip_storage.New(&http.Client{}, s3Cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		archiveSaver = s3ZipStorage
+
+	default:
+		return nil, fmt.Errorf("unknown archive storage %q", cfg.ArchiveStorage)
+	}
+
+	archiverCfg := archiver.Config{
+		MaxTasks:        cfg.Archiver.MaxTasks,
+		MaxObjects:      cfg.Archiver.MaxObjects,
+		DownloadWorkers: cfg.Archiver.DownloadWorkers,
+		TaskParallelism: cfg.Archiver.TaskParallelism,
+		MaxArchiveSize:  cfg.Archiver.MaxArchiveSize,
+		FixExtension:    cfg.Archiver.FixExtension,
+
+		ArchiveRetention: archiveRetention,
+	}
+
+	if ttl := cfg.Archiver.TaskTTL; ttl != nil {
+		archiverCfg.IdleTimeout = ttl.IdleTimeout
+		archiverCfg.Retention = ttl.Retention
+		archiverCfg.ExpiredRetention = ttl.ExpiredRetention
+		archiverCfg.JanitorInterval = ttl.JanitorInterval
+	}
+
+	var taskStore *file_task_store.Store
+	var store archiver.TaskStore
+
+	if cfg.Archiver.TaskStore != nil && cfg.Archiver.TaskStore.Dir != "" {
+		taskStore, err = file_task_store.New(cfg.Archiver.TaskStore.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		store = taskStore
+		archiverCfg.RequeueInterrupted = cfg.Archiver.TaskStore.RequeueInterrupted
+	}
+
+	// Without the task store the tasks of the uploads left from the previous run are gone
+	if store == nil {
+		if err := uploads.Clear(); err != nil {
+			return nil, err
+		}
+	}
+
+	var webhookCfg webhook.Config
+
+	if wh := cfg.Webhook; wh != nil {
+		webhookCfg = webhook.Config{
+			Secret:         string(wh.Secret),
+			Timeout:        wh.Timeout,
+			MaxAttempts:    wh.MaxAttempts,
+			InitialBackoff: wh.InitialBackoff,
+			MaxBackoff:     wh.MaxBackoff,
+			Workers:        wh.Workers,
+			LogRetention:   wh.LogRetention,
+		}
+	}
+
+	if webhookCfg.Secret == "" {
+		log.Warn("Webhook secret is not set, webhook payloads are not signed")
+	}
+
+	dispatcher := webhook.New(dialer.Client(), webhookCfg, log)
+
+	Archiver, err := archiver.New(archiverCfg, archiveObjectGetter, archiveSaver, store, dispatcher, log)
+	if err != nil {
+		return nil, err
+	}
 
 	if env == models.EnvProd {
 		gin.SetMode(gin.ReleaseMode)
@@ -68,10 +308,26 @@ func New(env string, cfg *config.Config, log *slog.Logger) (*App, error) {
 	router.Use(gin.Recovery())
 
 	router.GET("/task/new", new_task.New(Archiver, log))
-	router.POST("/task/:id/add", add_objects.New(Archiver, cfg.Archiver.ValidExtension, log))
+	router.POST("/task/new", new_task.New(Archiver, log))
+	router.POST("/task/:id/add", add_objects.New(Archiver, cfg.Archiver.ValidExtension, domains, credentialStore, archiveObjectGetter, uploads, log))
+	router.POST("/task/:id/upload", upload_objects.New(Archiver, uploads, cfg.Archiver.ValidExtension, maxUploadSize, log))
+	router.POST("/task/:id/start", start_task.New(Archiver, log))
+	router.POST("/task/:id/cancel", cancel_task.New(Archiver, log))
 	router.GET("/task/:id/status", get_status.New(Archiver, log))
+	router.POST("/task/:id/link", new_link.New(Archiver, log))
+	router.GET("/task/:id/events", task_events.New(Archiver, log))
+	router.GET("/task/:id/webhooks", task_webhooks.New(Archiver, dispatcher, log))
+	router.DELETE("/task/:id", delete_task.New(Archiver, log))
 
-	router.GET("/zips/:filename", zips_download.New(cfg.LocalZipStorage.Dir, log))
+	// S3 links point to the bucket directly
+	if cfg.ArchiveStorage != config.ArchiveStorageS3 {
+		zips, err := local_storage.New(cfg.LocalZipStorage.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		router.GET("/zips/:filename", zips_download.New(zips, zipLinks, log))
+	}
 
 	router.GET("/swagger/:any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -82,11 +338,75 @@ func New(env string, cfg *config.Config, log *slog.Logger) (*App, error) {
 	}
 
 	return &App{
-		server:   srv,
-		archiver: Archiver,
+		server:     srv,
+		archiver:   Archiver,
+		taskStore:  taskStore,
+		dispatcher: dispatcher,
 	}, nil
 }
 
+// newSchemes registers the getters of the source schemes: http(s), data and the uploads always,
+// file, ftp and s3 if they are configured.
+func newSchemes(cfg *config.ArchiveObjectGetter, client *http.Client, clientCfg http_client.Config, dialer *safe_dialer.Dialer, uploads *utils.Uploads, getterCfg utils.Config) (*utils.Schemes, error) {
+	schemes := utils.NewSchemes()
+
+	schemes.Register(utils.NewArchiveObjectGetter(client, getterCfg), "http", "https")
+	schemes.Register(utils.NewDataGetter(getterCfg), "data")
+	schemes.RegisterInternal(uploads, utils.UploadScheme)
+
+	if cfg.File != nil && len(cfg.File.Roots) > 0 {
+		fileGetter, err := utils.NewFileGetter(cfg.File.Roots, getterCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		schemes.Register(fileGetter, "file")
+	}
+
+	if cfg.FTP != nil {
+		schemes.Register(utils.NewFTPGetter(dialer, cfg.FTP.Timeout, getterCfg), "ftp")
+	}
+
+	if cfg.S3 != nil {
+		s3Cfg := utils.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: string(cfg.S3.SecretKey),
+			PathStyle: cfg.S3.PathStyle,
+			Buckets:   cfg.S3.Buckets,
+		}
+
+		if s3Cfg.AccessKey == "" {
+			s3Cfg.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		}
+		if s3Cfg.SecretKey == "" {
+			s3Cfg.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+
+		// The endpoint is configured by the operator, it may be internal,
+		// so only the timeouts of the object getter client are applied
+		s3Client, err := http_client.New(http_client.Config{
+			ConnectTimeout:        clientCfg.ConnectTimeout,
+			TLSHandshakeTimeout:   clientCfg.TLSHandshakeTimeout,
+			ResponseHeaderTimeout: clientCfg.ResponseHeaderTimeout,
+			Timeout:               clientCfg.Timeout,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		s3Getter, err := utils.NewS3Getter(s3Client, s3Cfg, getterCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		schemes.Register(s3Getter, "s3")
+	}
+
+	return schemes, nil
+}
+
 func MustNew(env string, cfg *config.Config, log *slog.Logger) *App {
 	app, err := New(env, cfg, log)
 	if err != nil {
@@ -112,18 +432,36 @@ func (app *App) MustRun(log *slog.Logger) {
 	}
 }
 
+// Shutdown stops every part of the app even if some of them fail or the context expires,
+// the errors are joined.
 func (app *App) Shutdown(ctx context.Context, log *slog.Logger) error {
+	var errs []error
+
 	if err := app.archiver.Stop(ctx); err != nil {
-		return err
+		errs = append(errs, err)
+	} else {
+		log.Info("Archiver service is stopped")
 	}
 
-	log.Info("Archiver service is stopped")
+	// After the archiver, so the notifications of the last tasks are sent
+	if err := app.dispatcher.Stop(ctx); err != nil {
+		errs = append(errs, err)
+	} else {
+		log.Info("Webhook dispatcher is stopped")
+	}
 
 	if err := app.server.Shutdown(ctx); err != nil {
-		return err
+		errs = append(errs, err)
+	} else {
+		log.Info("Server is shutdown")
 	}
 
-	log.Info("Server is shutdown")
+	// Last, the tasks still running after a timeout fail to persist instead of writing to a closed file
+	if app.taskStore != nil {
+		if err := app.taskStore.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a config value that must be kept out of the logs.
+// It is printed, marshaled and logged as a placeholder.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
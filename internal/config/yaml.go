@@ -7,10 +7,19 @@ import (
 	"time"
 )
 
+const (
+	ArchiveStorageLocal = "local"
+	ArchiveStorageS3    = "s3"
+)
+
 type Config struct {
 	Logger          *Logger          `yaml:"logger"`
 	Archiver        *Archiver        `yaml:"archiver"`
+	ArchiveStorage  string           `yaml:"archive_storage"`
 	LocalZipStorage *LocalZipStorage `yaml:"local_zip_storage"`
+	S3ZipStorage    *S3ZipStorage    `yaml:"s3_zip_storage"`
+	Webhook         *Webhook         `yaml:"webhook"`
+	SSRFProtection  *SSRFProtection  `yaml:"ssrf_protection"`
 	HttpServer      *HttpServer      `yaml:"http_server"`
 }
 
@@ -22,15 +31,132 @@ type Archiver struct {
 	MaxTasks            uint32               `yaml:"max_tasks"`
 	MaxObjects          int                  `yaml:"max_objects"`
 	ValidExtension      []string             `yaml:"valid_extension"`
+	FixExtension        bool                 `yaml:"fix_extension"`
+	AllowedDomains      []string             `yaml:"allowed_domains"`
+	BlockedDomains      []string             `yaml:"blocked_domains"`
+	DownloadWorkers     int                  `yaml:"download_workers"`
+	TaskParallelism     int                  `yaml:"task_parallelism"`
+	MaxArchiveSize      int64                `yaml:"max_archive_size"`
 	ArchiveObjectGetter *ArchiveObjectGetter `yaml:"archive_object_getter"`
+	TaskStore           *TaskStore           `yaml:"task_store"`
+	TaskTTL             *TaskTTL             `yaml:"task_ttl"`
+	Upload              *Upload              `yaml:"upload"`
+}
+
+type Upload struct {
+	Dir            string `yaml:"dir"`
+	MaxRequestSize int64  `yaml:"max_request_size"`
+}
+
+type TaskTTL struct {
+	IdleTimeout      time.Duration `yaml:"idle_timeout"`
+	Retention        time.Duration `yaml:"retention"`
+	ExpiredRetention time.Duration `yaml:"expired_retention"`
+	JanitorInterval  time.Duration `yaml:"janitor_interval"`
+}
+
+type TaskStore struct {
+	Dir                string `yaml:"dir"`
+	RequeueInterrupted bool   `yaml:"requeue_interrupted"`
 }
 
 type ArchiveObjectGetter struct {
-	ValidContentType []string `yaml:"valid_content_type"`
+	ValidContentType  []string `yaml:"valid_content_type"`
+	MaxObjectSize     int64    `yaml:"max_object_size"`
+	MaxConnsPerHost   int      `yaml:"max_conns_per_host"`
+	RequestsPerSecond float64  `yaml:"requests_per_second"`
+	Retry             *Retry   `yaml:"retry"`
+	HTTP              *HTTP    `yaml:"http"`
+	File              *File    `yaml:"file"`
+	FTP               *FTP     `yaml:"ftp"`
+	S3                *S3      `yaml:"s3"`
+	// Credentials are referred to by name in add_objects
+	Credentials map[string]Credential `yaml:"credentials"`
+}
+
+type Credential struct {
+	Domains []string          `yaml:"domains"`
+	Headers map[string]Secret `yaml:"headers"`
+}
+
+type HTTP struct {
+	ConnectTimeout        time.Duration     `yaml:"connect_timeout"`
+	TLSHandshakeTimeout   time.Duration     `yaml:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration     `yaml:"response_header_timeout"`
+	Timeout               time.Duration     `yaml:"timeout"`
+	Proxy                 string            `yaml:"proxy"`
+	UserAgent             string            `yaml:"user_agent"`
+	Headers               map[string]string `yaml:"headers"`
+	CAFile                string            `yaml:"ca_file"`
+	MaxRedirects          int               `yaml:"max_redirects"`
+	MaxIdleConns          int               `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost   int               `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout       time.Duration     `yaml:"idle_conn_timeout"`
+	DisableKeepAlives     bool              `yaml:"disable_keep_alives"`
+}
+
+type File struct {
+	Roots []string `yaml:"roots"`
+}
+
+type FTP struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type S3 struct {
+	Endpoint  string   `yaml:"endpoint"`
+	Region    string   `yaml:"region"`
+	AccessKey string   `yaml:"access_key"`
+	SecretKey Secret   `yaml:"secret_key"`
+	PathStyle bool     `yaml:"path_style"`
+	Buckets   []string `yaml:"buckets"`
+}
+
+type Retry struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+type S3ZipStorage struct {
+	Endpoint   string        `yaml:"endpoint"`
+	Region     string        `yaml:"region"`
+	Bucket     string        `yaml:"bucket"`
+	AccessKey  string        `yaml:"access_key"`
+	SecretKey  Secret        `yaml:"secret_key"`
+	PathStyle  bool          `yaml:"path_style"`
+	Prefix     string        `yaml:"prefix"`
+	PartSize   int64         `yaml:"part_size"`
+	LinkExpiry time.Duration `yaml:"link_expiry"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+type Webhook struct {
+	Secret         Secret        `yaml:"secret"`
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Workers        int           `yaml:"workers"`
+	LogRetention   time.Duration `yaml:"log_retention"`
 }
 
 type LocalZipStorage struct {
-	Dir string `yaml:"dir"`
+	Dir        string            `yaml:"dir"`
+	LinkSecret Secret            `yaml:"link_secret"`
+	LinkTTL    time.Duration     `yaml:"link_ttl"`
+	Retention  *ArchiveRetention `yaml:"retention"`
+}
+
+type ArchiveRetention struct {
+	MaxAge       time.Duration `yaml:"max_age"`
+	MaxTotalSize int64         `yaml:"max_total_size"`
+	MaxCount     int           `yaml:"max_count"`
+}
+
+type SSRFProtection struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
 }
 
 type HttpServer struct {
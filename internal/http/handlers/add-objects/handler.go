@@ -1,24 +1,67 @@
 package add_objects
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
 	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
 	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/fandasy/06.08.2025/internal/services/archiver/utils"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/net/http/httpguts"
 	"log/slog"
 	"net/http"
+	"net/textproto"
 	"net/url"
-	"path/filepath"
+	"strings"
 )
 
+// Spool removes the uploaded files replaced by the added objects, e.g. utils.Uploads.
+type Spool interface {
+	Remove(link string) error
+}
+
 type Request struct {
-	Urls []string `json:"urls"`
+	Urls []Item `json:"urls"`
+	// CallbackURL replaces the callback URL of the task if set
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// Item is a URL string or an object with the url and the optional name and path of the entry.
+type Item struct {
+	URL string `json:"url"`
+	// Name of the entry inside the archive, by default it is taken from the URL
+	Name string `json:"name,omitempty"`
+	// Path is the directory of the entry inside the archive
+	Path string `json:"path,omitempty"`
+	// Headers are sent with the request for the object, e.g. Authorization or Cookie.
+	// They are not logged, persisted or returned by get_status.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Credential is the name of a credential from archiver.archive_object_getter.credentials
+	Credential string `json:"credential,omitempty"`
+}
+
+func (i *Item) UnmarshalJSON(data []byte) error {
+	var u string
+	if err := json.Unmarshal(data, &u); err == nil {
+		*i = Item{URL: u}
+		return nil
+	}
+
+	type item Item
+
+	return json.Unmarshal(data, (*item)(i))
 }
 
 type Response struct {
-	Added int   `json:"added"`
-	Urls  []Url `json:"urls,omitempty"`
+	Added int `json:"added"`
+	// Replaced is the number of the earlier objects replaced under the overwrite name conflict policy
+	Replaced int   `json:"replaced,omitempty"`
+	Urls     []Url `json:"urls,omitempty"`
 }
 
 type Url struct {
@@ -29,20 +72,33 @@ type Url struct {
 // New godoc
 // @Summary      Добавить объекты в задачу архивации
 // @Description  Добавляет один или несколько файловых URL в существующую задачу архивации.
+// @Description  URL с неподдерживаемым расширением или доменом вне списка разрешённых (archiver.allowed_domains, archiver.blocked_domains) не добавляется, ошибка возвращается для каждого URL.
+// @Description  Кроме http(s) поддерживаются схемы data: (RFC 2397), file:// (только каталоги archive_object_getter.file.roots), ftp:// и s3://bucket/key, если они настроены. URL с неподключённой схемой даёт ошибку "unsupported scheme", файл вне разрешённых каталогов или бакет вне разрешённых — "source not allowed".
+// @Description  Элемент urls — строка с URL или объект {url, name, path}: name задаёт имя файла в архиве, path — папку внутри архива.
+// @Description  Для защищённых источников (http(s) и ftp) объект может содержать headers — заголовки запроса (Authorization, Cookie, X-Api-Key и т.п.) и/или credential — имя учётных данных из конфигурации (archiver.archive_object_getter.credentials).
+// @Description  Заголовки отправляются только на хост из URL объекта, не на хосты редиректов; они не пишутся в логи, не сохраняются на диск и не возвращаются в get_status, поэтому незавершённая задача с заголовками после перезапуска сервиса завершается ошибкой.
+// @Description  Некорректные или служебные заголовки (Host, Content-Length, Range, Accept-Encoding и т.п.) дают ошибку URL "invalid headers", неизвестные учётные данные или учётные данные не для домена URL — "credential not allowed".
+// @Description  Без name имя берётся из URL с префиксом-номером объекта. Имена и папки с "..", ":", обратной косой чертой или управляющими символами, а также абсолютные пути отклоняются.
+// @Description  Совпадение имени с уже добавленным объектом обрабатывается по политике name_conflict задачи: suffix (file (1).pdf), overwrite (замена объекта) или fail (409).
+// @Description  Необязательный callback_url заменяет URL, на который будет отправлено уведомление о завершении задачи; он регистрируется до добавления объектов.
 // @Tags         tasks
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string      true  "ID задачи"
-// @Param        request  body  Request     true  "Список URL-адресов для добавления"  example({"urls": ["https://example.com/file1.pdf", "https://example.com/image1.jpeg"]})
+// @Param        request  body  Request     true  "Список URL-адресов для добавления"  example({"urls": ["https://example.com/file1.pdf", {"url": "https://example.com/image1.jpeg", "name": "cover.jpeg", "path": "images"}, {"url": "https://files.example.com/report.pdf", "headers": {"Authorization": "Bearer token"}}]})
 // @Success      200  {object}  Response    "Ссылки успешно добавлены в задачу"
 // @Failure      400  {object}  response.ErrorResponse "Некорректный запрос"
 // @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
 // @Failure      400  {object}  response.ErrorResponse "Тело запроса невалидно (не JSON)"
 // @Failure      400  {object}  response.ErrorResponse "Список URL пуст ('urls is empty')"
 // @Failure      400  {object}  response.ErrorResponse "Нет поддерживаемых URL ('no valid urls')"
+// @Failure      400  {object}  response.ErrorResponse "Некорректный callback_url ('invalid callback url')"
+// @Failure      409  {object}  response.ErrorResponse "Имя уже занято при политике fail ('object name already taken')"
 // @Failure      400  {object}  response.ErrorResponse "Задача уже в обработке ('Task is in progress')"
 // @Failure      400  {object}  response.ErrorResponse "Задача уже завершена ('Task is completed')"
+// @Failure      400  {object}  response.ErrorResponse "Задача отменена ('Task is canceled')"
 // @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      410  {object}  response.ErrorResponse "Задача истекла ('Task expired')"
 // @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
 // @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
 // @Example      {json}  Успешный запрос:
@@ -50,17 +106,21 @@ type Url struct {
 //	{
 //	  "urls": [
 //	    "https://example.com/file1.pdf",
-//	    "https://example.com/file2.jpeg"
+//	    {"url": "https://example.com/file2.jpeg", "name": "cover.jpeg", "path": "images"},
+//	    {"url": "https://files.example.com/report.pdf", "headers": {"Authorization": "Bearer token"}},
+//	    {"url": "https://cdn.example.com/photo.jpeg", "credential": "cdn"}
 //	  ]
 //	}
 //
 // @Example      {json}  Успешный ответ:
 //
 //	{
-//	  "added": 2,
+//	  "added": 3,
 //	  "urls": [
 //	    {"url": "https://example.com/file1.pdf"},
-//	    {"url": "https://example.com/image1.jpeg"}
+//	    {"url": "https://example.com/image1.jpeg"},
+//	    {"url": "https://files.example.com/report.pdf"},
+//	    {"url": "https://cdn.example.com/photo.jpeg", "error": "credential not allowed"}
 //	  ]
 //	}
 //
@@ -82,6 +142,12 @@ type Url struct {
 //	  "error": "no valid urls"
 //	}
 //
+// @Example      {json}  Ошибка: Имя уже занято:
+//
+//	{
+//	  "error": "object name already taken"
+//	}
+//
 // @Example      {json}  Ошибка: Задача уже в обработке:
 //
 //	{
@@ -100,6 +166,12 @@ type Url struct {
 //	  "error": "Task not found"
 //	}
 //
+// @Example      {json}  Ошибка: Задача истекла:
+//
+//	{
+//	  "error": "Task expired"
+//	}
+//
 // @Example      {json}  Ошибка: Сервис архивации остановлен:
 //
 //	{
@@ -107,7 +179,7 @@ type Url struct {
 //	}
 //
 // @Router       /task/{id}/add [post]
-func New(archiverService archiver.Archiver, validExtension []string, log *slog.Logger) gin.HandlerFunc {
+func New(archiverService archiver.Archiver, validExtension []string, domains *domain_policy.Policy, creds *credentials.Store, schemes *utils.Schemes, spool Spool, log *slog.Logger) gin.HandlerFunc {
 	const fn = "handlers.add_objects.New"
 
 	log = log.With("fn", fn)
@@ -155,17 +227,44 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 
 		var resp Response
 
-		urls := make([]string, 0, len(req.Urls))
-		for _, u := range req.Urls {
-			if err := extensionValidate(u, validExtensionMap); err != nil {
-				resp.Urls = append(resp.Urls, Url{Value: u, Err: err.Error()})
+		objs := make([]archiver.NewObject, 0, len(req.Urls))
+		for _, item := range req.Urls {
+			u, err := urlValidate(item.URL, schemes, validExtensionMap)
+			if err != nil {
+				resp.Urls = append(resp.Urls, Url{Value: item.URL, Err: err.Error()})
+				continue
+			}
+			networked := isNetworkScheme(u.Scheme)
+			if networked {
+				if err := domains.CheckURL(item.URL); err != nil {
+					resp.Urls = append(resp.Urls, Url{Value: item.URL, Err: ErrDomainNotAllowed.Error()})
+					continue
+				}
+			}
+			if err := archiver.ValidateEntry(item.Path, item.Name); err != nil {
+				resp.Urls = append(resp.Urls, Url{Value: item.URL, Err: ErrInvalidName.Error()})
 				continue
 			}
-			resp.Urls = append(resp.Urls, Url{Value: u})
-			urls = append(urls, u)
+			if err := headersValidate(item.Headers); err != nil || len(item.Headers) > 0 && !networked {
+				resp.Urls = append(resp.Urls, Url{Value: item.URL, Err: ErrInvalidHeaders.Error()})
+				continue
+			}
+			if item.Credential != "" {
+				if err := creds.Check(item.Credential, item.URL); err != nil || !networked {
+					resp.Urls = append(resp.Urls, Url{Value: item.URL, Err: ErrCredentialNotAllowed.Error()})
+					continue
+				}
+			}
+			resp.Urls = append(resp.Urls, Url{Value: item.URL})
+			objs = append(objs, archiver.NewObject{
+				URL:  item.URL,
+				Name: item.Name,
+				Path: item.Path,
+				Auth: object_storage.SourceAuth{Headers: item.Headers, Credential: item.Credential},
+			})
 		}
 
-		if len(urls) == 0 {
+		if len(objs) == 0 {
 			log.Debug("No valid URLs")
 
 			c.JSON(http.StatusBadRequest, response.Error("no valid urls"))
@@ -173,7 +272,17 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 			return
 		}
 
-		added, err := archiverService.AddObjects(taskID, urls)
+		// The callback is registered first, so it is in place if the task gets filled up
+		var err error
+		if req.CallbackURL != "" {
+			err = archiverService.SetCallback(taskID, req.CallbackURL)
+		}
+
+		var result archiver.AddResult
+		if err == nil {
+			result, err = archiverService.AddObjects(taskID, objs)
+		}
+
 		if err != nil {
 			switch {
 			case errors.Is(err, archiver.ErrServiceStopped):
@@ -181,6 +290,20 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 
 				return
 
+			case errors.Is(err, archiver.ErrInvalidCallbackURL):
+				log.Debug(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusBadRequest, response.Error("invalid callback url"))
+
+				return
+
+			case errors.Is(err, archiver.ErrNameConflict):
+				log.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusConflict, response.Error("object name already taken"))
+
+				return
+
 			case errors.Is(err, archiver.ErrTaskNotFound):
 				log.Warn(err.Error(), slog.String("task id", taskID))
 
@@ -202,6 +325,20 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 
 				return
 
+			case errors.Is(err, archiver.ErrTaskCanceled):
+				log.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusBadRequest, response.Error("Task is canceled"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskExpired):
+				log.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusGone, response.Error("Task expired"))
+
+				return
+
 			default:
 				log.Error(err.Error())
 
@@ -211,21 +348,33 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 			}
 		}
 
-		if added < len(urls) {
+		// The uploaded files replaced under the overwrite policy are not archived
+		for _, src := range result.Replaced {
+			if !utils.IsUploadLink(src) {
+				continue
+			}
+
+			if err := spool.Remove(src); err != nil {
+				log.Error("Failed to remove uploaded file", slog.String("task id", taskID), sl.Err(err))
+			}
+		}
+
+		if accepted := result.Accepted(); accepted < len(objs) {
 			validCount := 0
 			for i := range resp.Urls {
 				if resp.Urls[i].Err == "" {
 					validCount++
-					if validCount > added {
+					if validCount > accepted {
 						resp.Urls[i].Err = ErrNoMorePlacesAvailable.Error()
 					}
 				}
 			}
 		}
 
-		resp.Added = added
+		resp.Added = result.Added
+		resp.Replaced = len(result.Replaced)
 
-		log.Info("Urls successfully added to task", slog.String("task id", taskID), slog.Any("urls", resp.Urls))
+		log.Info("Urls successfully added to task", slog.String("task id", taskID), slog.Any("urls", logUrls(resp.Urls)))
 
 		c.JSON(http.StatusOK, resp)
 	}
@@ -233,21 +382,106 @@ func New(archiverService archiver.Archiver, validExtension []string, log *slog.L
 
 var (
 	ErrIncorrectUrl          = errors.New("incorrect url")
+	ErrUnsupportedScheme     = errors.New("unsupported scheme")
+	ErrSourceNotAllowed      = errors.New("source not allowed")
 	ErrInvalidExtension      = errors.New("invalid extension")
+	ErrInvalidName           = errors.New("invalid name or path")
+	ErrDomainNotAllowed      = errors.New("domain not allowed")
+	ErrInvalidHeaders        = errors.New("invalid headers")
+	ErrCredentialNotAllowed  = errors.New("credential not allowed")
 	ErrNoMorePlacesAvailable = errors.New("no more places available")
 )
 
-func extensionValidate(u string, valid map[string]struct{}) error {
-	parsedURL, err := url.Parse(u)
-	if err != nil || parsedURL.Scheme == "" || parsedURL.Host == "" {
-		return ErrIncorrectUrl
+// maxHeaders limits the headers of one object
+const maxHeaders = 32
+
+// forbiddenHeaders are managed by the http client or change what is downloaded
+var forbiddenHeaders = map[string]struct{}{
+	"Host":                {},
+	"Content-Length":      {},
+	"Transfer-Encoding":   {},
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Upgrade":             {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Proxy-Authorization": {},
+	"Proxy-Connection":    {},
+	"Range":               {},
+	"If-Range":            {},
+	"Accept-Encoding":     {},
+}
+
+func headersValidate(headers map[string]string) error {
+	if len(headers) > maxHeaders {
+		return ErrInvalidHeaders
 	}
 
-	if valid != nil {
-		if _, ok := valid[filepath.Ext(u)]; !ok {
-			return ErrInvalidExtension
+	for name, value := range headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return ErrInvalidHeaders
+		}
+
+		if _, ok := forbiddenHeaders[textproto.CanonicalMIMEHeaderKey(name)]; ok {
+			return ErrInvalidHeaders
 		}
 	}
 
 	return nil
 }
+
+// urlValidate checks the URL with the getter of its scheme and the extension of the object.
+func urlValidate(link string, schemes *utils.Schemes, valid map[string]struct{}) (*url.URL, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme == "" {
+		return nil, ErrIncorrectUrl
+	}
+
+	if err := schemes.Validate(link); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrUnsupportedScheme):
+			return nil, ErrUnsupportedScheme
+		case errors.Is(err, utils.ErrAccessDenied):
+			return nil, ErrSourceNotAllowed
+		}
+
+		return nil, ErrIncorrectUrl
+	}
+
+	if valid != nil && !hasValidExtension(u, valid) {
+		return nil, ErrInvalidExtension
+	}
+
+	return u, nil
+}
+
+func hasValidExtension(u *url.URL, valid map[string]struct{}) bool {
+	for _, ext := range utils.Extensions(u) {
+		if _, ok := valid[ext]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isNetworkScheme reports whether the URLs of the scheme point to a host on the network:
+// only those are checked against the domain lists and can carry headers and credentials.
+func isNetworkScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "http", "https", "ftp":
+		return true
+	}
+
+	return false
+}
+
+// logUrls shortens data URLs and hides passwords.
+func logUrls(urls []Url) []Url {
+	logged := make([]Url, len(urls))
+	for i, u := range urls {
+		logged[i] = Url{Value: sl.RedactURL(u.Value), Err: u.Err}
+	}
+
+	return logged
+}
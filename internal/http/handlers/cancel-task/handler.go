@@ -0,0 +1,118 @@
+package cancel_task
+
+import (
+	"errors"
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+type Response struct {
+	Status string `json:"status"`
+}
+
+// New godoc
+// @Summary      Отменить задачу архивации
+// @Description  Отменяет задачу, ожидающую объекты или находящуюся в архивации. Загрузки прерываются, слот задачи освобождается, частично записанный архив удаляется.
+// @Tags         tasks
+// @Produce      json
+// @Param        id   path      string  true  "ID задачи"
+// @Success      200  {object}  Response  "Задача отменена"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      400  {object}  response.ErrorResponse "Задача уже завершена ('Task is completed')"
+// @Failure      400  {object}  response.ErrorResponse "Задача уже отменена ('Task is canceled')"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      410  {object}  response.ErrorResponse "Задача истекла ('Task expired')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {json}  Успешный ответ:
+//
+//	{
+//	  "status": "Canceled"
+//	}
+//
+// @Example      {json}  Ошибка: Задача уже завершена:
+//
+//	{
+//	  "error": "Task is completed"
+//	}
+//
+// @Example      {json}  Ошибка: Задача не найдена:
+//
+//	{
+//	  "error": "Task not found"
+//	}
+//
+// @Router       /task/{id}/cancel [post]
+func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.cancel_task.New"
+
+	log = log.With("fn", fn)
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		if err := archiverService.CancelTask(taskID); err != nil {
+			switch {
+			case errors.Is(err, archiver.ErrServiceStopped):
+				c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskNotFound):
+				l.Warn(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskCompleted):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusBadRequest, response.Error("Task is completed"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskCanceled):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusBadRequest, response.Error("Task is canceled"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskExpired):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusGone, response.Error("Task expired"))
+
+				return
+
+			default:
+				l.Error(err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+
+		l.Info("Task canceled", slog.String("task id", taskID))
+
+		c.JSON(http.StatusOK, Response{Status: archiver.StatusCanceled.String()})
+	}
+}
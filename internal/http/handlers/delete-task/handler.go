@@ -0,0 +1,78 @@
+package delete_task
+
+import (
+	"errors"
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+)
+
+// New godoc
+// @Summary      Удалить задачу архивации
+// @Description  Удаляет задачу вместе с её архивом. Задача, находящаяся в работе, предварительно отменяется.
+// @Tags         tasks
+// @Produce      json
+// @Param        id   path      string  true  "ID задачи"
+// @Success      204  "Задача удалена"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {json}  Ошибка: Задача не найдена:
+//
+//	{
+//	  "error": "Task not found"
+//	}
+//
+// @Router       /task/{id} [delete]
+func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.delete_task.New"
+
+	log = log.With("fn", fn)
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		if err := archiverService.DeleteTask(taskID); err != nil {
+			switch {
+			case errors.Is(err, archiver.ErrServiceStopped):
+				c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskNotFound):
+				l.Warn(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+				return
+
+			default:
+				l.Error(err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+
+		l.Info("Task deleted", slog.String("task id", taskID))
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"errors"
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
 	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
 	"github.com/fandasy/06.08.2025/internal/services/archiver"
 	"github.com/fandasy/06.08.2025/internal/services/archiver/utils"
 	"github.com/gin-gonic/gin"
@@ -13,20 +17,27 @@ import (
 
 type Response struct {
 	Status  string    `json:"status"`
+	Format  string    `json:"format,omitempty"`
 	Objects []Objects `json:"objects"`
 
+	// Encrypted is set for archives protected with a password
+	Encrypted bool `json:"encrypted,omitempty"`
+
 	Zip string `json:"zip,omitempty"`
 	Err string `json:"error,omitempty"`
 }
 
 type Objects struct {
-	Src string `json:"src,omitempty"`
-	Err string `json:"error,omitempty"`
+	Src      string `json:"src,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Err      string `json:"error,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
 }
 
 // New godoc
 // @Summary      Получить статус задачи архивации
-// @Description  Возвращает текущий статус задачи архивации, список объектов, ошибки и ссылку на архив (если задача завершена).
+// @Description  Возвращает текущий статус задачи архивации, список объектов, ошибки и ссылку на архив (если задача завершена). Если архив удалён по политике хранения, статус — "Archive expired", ссылка не возвращается. Для архивов с паролем возвращается "encrypted": true.
 // @Tags         tasks
 // @Produce      json
 // @Param        id   path      string  true  "ID задачи"
@@ -39,14 +50,37 @@ type Objects struct {
 //
 //	{
 //	  "status": "Done",
+//	  "format": "zip",
 //	  "objects": [
-//	    { "src": "https://example.com/file1.pdf" },
-//	    { "src": "https://example.com/file2.jpeg", "error": "file not found" }
+//	    { "src": "https://example.com/file1.pdf", "attempts": 1 },
+//	    { "src": "https://example.com/file2.jpeg", "error": "file not found", "attempts": 1 },
+//	    { "src": "https://example.com/file3.png", "name": "cover.png", "path": "images", "attempts": 3 }
 //	  ],
 //	  "zip": "http://localhost:8080/storage/12345.zip",
 //	  "error": ""
 //	}
 //
+// @Example      {json}  Архив с паролем:
+//
+//	{
+//	  "status": "Done",
+//	  "format": "zip",
+//	  "objects": [
+//	    { "src": "https://example.com/file1.pdf", "attempts": 1 }
+//	  ],
+//	  "encrypted": true,
+//	  "zip": "http://localhost:8080/storage/12345.zip"
+//	}
+//
+// @Example      {json}  Архив удалён по политике хранения:
+//
+//	{
+//	  "status": "Archive expired",
+//	  "objects": [
+//	    { "src": "https://example.com/file1.pdf", "attempts": 1 }
+//	  ]
+//	}
+//
 // @Example      {json}  Ошибка: Параметр taskID отсутствует:
 //
 //	{
@@ -110,32 +144,43 @@ func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
 			}
 		}
 
-		log.Info("Information about the task has been received", slog.String("task id", taskID), slog.Any("info", taskInfo))
+		resp := BuildResponse(taskInfo)
 
-		objs := make([]Objects, 0, len(taskInfo.Objects))
-		for _, obj := range taskInfo.Objects {
-			objErr := prepareClientObjErr(obj.Err)
+		log.Info("Information about the task has been received", slog.String("task id", taskID), slog.Any("info", resp))
 
-			objs = append(objs, Objects{
-				Src: obj.Src,
-				Err: objErr,
-			})
-		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
 
-		taskErr := prepareClientTaskErr(taskInfo.Err)
+// BuildResponse converts the task info into the client representation,
+// internal errors are replaced with client messages and the sources are redacted.
+func BuildResponse(taskInfo *archiver.TaskInfo) Response {
+	objs := make([]Objects, 0, len(taskInfo.Objects))
+	for _, obj := range taskInfo.Objects {
+		objErr := PrepareClientObjErr(obj.Err)
+
+		objs = append(objs, Objects{
+			Src:      sl.RedactURL(obj.Src),
+			Name:     obj.Name,
+			Path:     obj.Path,
+			Err:      objErr,
+			Attempts: obj.Attempts,
+		})
+	}
 
-		resp := Response{
-			Status:  taskInfo.Status.String(),
-			Objects: objs,
-			Zip:     taskInfo.Zip,
-			Err:     taskErr,
-		}
+	taskErr := PrepareClientTaskErr(taskInfo.Err)
 
-		c.JSON(http.StatusOK, resp)
+	return Response{
+		Status:    taskInfo.Status.String(),
+		Format:    string(taskInfo.Format),
+		Objects:   objs,
+		Encrypted: taskInfo.Encrypted,
+		Zip:       taskInfo.Zip,
+		Err:       taskErr,
 	}
 }
 
-func prepareClientObjErr(err error) string {
+func PrepareClientObjErr(err error) string {
 	if err == nil {
 		return ""
 	}
@@ -153,12 +198,26 @@ func prepareClientObjErr(err error) string {
 		return "Access Denied"
 	case errors.Is(err, utils.ErrInternalSourceError):
 		return "Internal Source"
+	case errors.Is(err, utils.ErrFileTooLarge):
+		return "File too large"
+	case errors.Is(err, utils.ErrUnsupportedScheme):
+		return "Unsupported scheme"
+	case errors.Is(err, utils.ErrInvalidURL):
+		return "Invalid URL"
+	case errors.Is(err, safe_dialer.ErrAddressBlocked):
+		return "Address not allowed"
+	case errors.Is(err, domain_policy.ErrDomainNotAllowed):
+		return "Domain not allowed"
+	case errors.Is(err, credentials.ErrUnknownCredential):
+		return "Credential not allowed"
+	case errors.Is(err, archiver.ErrArchiveTooLarge):
+		return "Archive size limit exceeded"
 	default:
 		return "Internal Error"
 	}
 }
 
-func prepareClientTaskErr(err error) string {
+func PrepareClientTaskErr(err error) string {
 	if err == nil {
 		return ""
 	}
@@ -166,6 +225,12 @@ func prepareClientTaskErr(err error) string {
 	switch {
 	case errors.Is(err, archiver.ErrNoObjectsToArchive):
 		return "No objects to archive"
+	case errors.Is(err, archiver.ErrTaskInterrupted):
+		return "Task interrupted by service restart"
+	case errors.Is(err, archiver.ErrPasswordLost):
+		return "Archive password lost on service restart"
+	case errors.Is(err, archiver.ErrHeadersLost):
+		return "Request headers lost on service restart"
 	default:
 		return "Internal Error"
 	}
@@ -0,0 +1,137 @@
+package new_link
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/gin-gonic/gin"
+)
+
+type Response struct {
+	Zip string `json:"zip"`
+}
+
+// New godoc
+// @Summary      Получить новую ссылку на архив
+// @Description  Выпускает новую подписанную ссылку на архив завершённой задачи. Новая ссылка также возвращается в статусе задачи.
+// @Tags         tasks
+// @Produce      json
+// @Param        id   path      string  true   "ID задачи"
+// @Param        ttl  query     string  false  "Время жизни ссылки, например 1h30m. По умолчанию — из конфига хранилища"
+// @Success      200  {object}  Response  "Новая ссылка на архив"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      400  {object}  response.ErrorResponse "Неверный параметр ttl ('Invalid ttl')"
+// @Failure      400  {object}  response.ErrorResponse "У задачи нет архива ('Task has no archive')"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      410  {object}  response.ErrorResponse "Задача истекла ('Task expired')"
+// @Failure      410  {object}  response.ErrorResponse "Архив удалён по политике хранения ('Archive expired')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {json}  Успешный ответ:
+//
+//	{
+//	  "zip": "http://localhost:8080/zips/12345?expires=1760000000&signature=3f1c..."
+//	}
+//
+// @Example      {json}  Ошибка: У задачи нет архива:
+//
+//	{
+//	  "error": "Task has no archive"
+//	}
+//
+// @Example      {json}  Ошибка: Архив удалён:
+//
+//	{
+//	  "error": "Archive expired"
+//	}
+//
+// @Router       /task/{id}/link [post]
+func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.new_link.New"
+
+	log = log.With("fn", fn)
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		var ttl time.Duration
+
+		if raw := c.Query("ttl"); raw != "" {
+			var err error
+			ttl, err = time.ParseDuration(raw)
+			if err != nil || ttl <= 0 {
+				l.Debug("Invalid ttl", slog.String("ttl", raw))
+
+				c.JSON(http.StatusBadRequest, response.Error("Invalid ttl"))
+
+				return
+			}
+		}
+
+		link, err := archiverService.NewLink(taskID, ttl)
+		if err != nil {
+			switch {
+			case errors.Is(err, archiver.ErrServiceStopped):
+				c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskNotFound):
+				l.Warn(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+				return
+
+			case errors.Is(err, archiver.ErrNoArchive):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusBadRequest, response.Error("Task has no archive"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskExpired):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusGone, response.Error("Task expired"))
+
+				return
+
+			case errors.Is(err, archiver.ErrArchiveExpired):
+				l.Info(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusGone, response.Error("Archive expired"))
+
+				return
+
+			default:
+				l.Error(err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+
+		l.Info("Archive link issued", slog.String("task id", taskID))
+
+		c.JSON(http.StatusOK, Response{Zip: link})
+	}
+}
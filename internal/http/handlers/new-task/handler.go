@@ -3,23 +3,53 @@ package new_task
 import (
 	"errors"
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
 	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
 	"github.com/fandasy/06.08.2025/internal/services/archiver"
 	"github.com/gin-gonic/gin"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 )
 
+type Request struct {
+	CallbackURL string `json:"callback_url,omitempty"`
+	Format      string `json:"format,omitempty"`
+	// Password encrypts zip entries with AES-256, accepted only in the body
+	Password string `json:"password,omitempty"`
+	// Manifest adds manifest.json and SHA256SUMS to the archive
+	Manifest bool `json:"manifest,omitempty"`
+	// NameConflict is the policy for the objects named by the client: suffix, overwrite or fail
+	NameConflict string `json:"name_conflict,omitempty"`
+}
+
 type Response struct {
 	ID string `json:"id"`
 }
 
 // New godoc
 // @Summary      Создать новую задачу архивации
-// @Description  Создаёт новую задачу для добавления файловых ссылок и последующего создания ZIP-архива.
+// @Description  Создаёт новую задачу для добавления файловых ссылок и последующего создания архива.
+// @Description  POST принимает необязательное тело с callback_url: по завершении задачи (Done или Error) на него отправляется подписанный POST с телом как у /task/{id}/status.
+// @Description  Формат архива (zip, tar, tar.gz, tar.zst) задаётся полем format тела или параметром запроса format, по умолчанию zip.
+// @Description  Поле password тела шифрует файлы zip-архива AES-256 (WinZip AE-2, открывается 7-Zip и Windows). Пароль не логируется и не сохраняется на диск: незавершённая задача с паролем после перезапуска сервиса завершается ошибкой.
+// @Description  Поле manifest тела или параметр запроса manifest=true добавляет в архив manifest.json (источник, итоговый URL после редиректов, Content-Type, размер, SHA-256 и время загрузки каждого объекта, а также ошибки незагруженных объектов) и SHA256SUMS.
+// @Description  Поле name_conflict тела или параметр запроса name_conflict задаёт обработку совпадающих имён объектов, добавленных с name: suffix (по умолчанию), overwrite или fail.
 // @Tags         tasks
+// @Accept       json
 // @Produce      json
+// @Param        format   query  string   false  "Формат архива: zip, tar, tar.gz, tar.zst"  Enums(zip, tar, tar.gz, tar.zst)
+// @Param        manifest query  bool     false  "Добавить в архив manifest.json и SHA256SUMS"
+// @Param        name_conflict query  string  false  "Совпадение имён объектов: suffix, overwrite, fail"  Enums(suffix, overwrite, fail)
+// @Param        request  body  Request  false  "Необязательные параметры задачи (только POST)"  example({"callback_url": "https://example.com/hooks/archive", "format": "tar.gz", "password": "secret", "manifest": true, "name_conflict": "suffix"})
 // @Success      200  {object}  Response  "Задача успешно создана"
+// @Failure      400  {object}  response.ErrorResponse "Тело запроса невалидно (не JSON)"
+// @Failure      400  {object}  response.ErrorResponse "Некорректный callback_url ('invalid callback url')"
+// @Failure      400  {object}  response.ErrorResponse "Неизвестный формат архива ('invalid archive format')"
+// @Failure      400  {object}  response.ErrorResponse "Некорректный параметр manifest ('invalid manifest parameter')"
+// @Failure      400  {object}  response.ErrorResponse "Неизвестная политика совпадения имён ('invalid name conflict policy')"
+// @Failure      400  {object}  response.ErrorResponse "Пароль задан не для zip-архива ('password is supported only for zip archives')"
 // @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
 // @Failure      503  {object}  response.ErrorResponse "Превышен лимит одновременно выполняемых задач"
 // @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
@@ -41,7 +71,26 @@ type Response struct {
 //	  "error": "Max tasks exceeded"
 //	}
 //
+// @Example      {json}  Ошибка: Некорректный callback_url:
+//
+//	{
+//	  "error": "invalid callback url"
+//	}
+//
+// @Example      {json}  Ошибка: Неизвестный формат архива:
+//
+//	{
+//	  "error": "invalid archive format"
+//	}
+//
+// @Example      {json}  Ошибка: Пароль задан не для zip-архива:
+//
+//	{
+//	  "error": "password is supported only for zip archives"
+//	}
+//
 // @Router       /task/new [get]
+// @Router       /task/new [post]
 func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
 	const fn = "handlers.new_task.New"
 
@@ -53,7 +102,42 @@ func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
 			log = log.With("request id", requestID)
 		}
 
-		id, err := archiverService.NewTask()
+		// The body is optional, GET requests have none
+		var req Request
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			log.Error(err.Error())
+
+			c.JSON(http.StatusBadRequest, response.Error("request body is not valid"))
+
+			return
+		}
+
+		if req.Format == "" {
+			req.Format = c.Query("format")
+		}
+
+		if req.NameConflict == "" {
+			req.NameConflict = c.Query("name_conflict")
+		}
+
+		if manifest, ok := c.GetQuery("manifest"); ok && !req.Manifest {
+			var err error
+			if req.Manifest, err = strconv.ParseBool(manifest); err != nil {
+				log.Debug("Invalid manifest parameter", slog.String("manifest", manifest))
+
+				c.JSON(http.StatusBadRequest, response.Error("invalid manifest parameter"))
+
+				return
+			}
+		}
+
+		id, err := archiverService.NewTask(archiver.TaskOptions{
+			CallbackURL:  req.CallbackURL,
+			Format:       object_storage.ArchiveFormat(req.Format),
+			Password:     req.Password,
+			Manifest:     req.Manifest,
+			NameConflict: archiver.NameConflict(req.NameConflict),
+		})
 		if err != nil {
 			switch {
 			case errors.Is(err, archiver.ErrServiceStopped):
@@ -61,6 +145,34 @@ func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
 
 				return
 
+			case errors.Is(err, archiver.ErrInvalidCallbackURL):
+				log.Debug(err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error("invalid callback url"))
+
+				return
+
+			case errors.Is(err, archiver.ErrInvalidFormat):
+				log.Debug(err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error("invalid archive format"))
+
+				return
+
+			case errors.Is(err, archiver.ErrInvalidNameConflict):
+				log.Debug(err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error("invalid name conflict policy"))
+
+				return
+
+			case errors.Is(err, archiver.ErrPasswordNotSupported):
+				log.Debug(err.Error())
+
+				c.JSON(http.StatusBadRequest, response.Error("password is supported only for zip archives"))
+
+				return
+
 			case errors.Is(err, archiver.ErrMaxTasksExceeded):
 				log.Warn("Maximum number of tasks exceeded")
 
@@ -0,0 +1,163 @@
+package task_events
+
+import (
+	"errors"
+	get_status "github.com/fandasy/06.08.2025/internal/http/handlers/get-status"
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Event is the data of every event except "status",
+// the name of the SSE event is the event type.
+type Event struct {
+	// Object is the index of the object in the task, absent for the events of the whole task
+	Object *int   `json:"object,omitempty"`
+	Src    string `json:"src,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Zip    string `json:"zip,omitempty"`
+	Err    string `json:"error,omitempty"`
+}
+
+// New godoc
+// @Summary      Поток событий задачи (SSE)
+// @Description  Открывает поток Server-Sent Events с прогрессом задачи. Первым приходит событие "status" с текущим состоянием задачи (как в /task/{id}/status),
+// @Description  затем события: object_added, download_started, download_finished, download_failed, archive_written, task_done, task_error, task_canceled, task_expired.
+// @Description  Поток закрывается после task_done, task_error, task_canceled, task_expired, удаления задачи или остановки сервиса. Если задача уже завершена, приходит только "status".
+// @Tags         tasks
+// @Produce      text/event-stream
+// @Param        id   path      string  true  "ID задачи"
+// @Success      200  {object}  Event  "Поток событий"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {text}  Поток событий:
+//
+//	event:status
+//	data:{"status":"Archiving","objects":[{"src":"https://example.com/file1.pdf"}]}
+//
+//	event:download_started
+//	data:{"object":0,"src":"https://example.com/file1.pdf"}
+//
+//	event:download_finished
+//	data:{"object":0,"src":"https://example.com/file1.pdf","size":52431}
+//
+//	event:archive_written
+//	data:{"size":52431,"zip":"http://localhost:8080/zips/12345.zip"}
+//
+//	event:task_done
+//	data:{"zip":"http://localhost:8080/zips/12345.zip"}
+//
+// @Example      {json}  Ошибка: Задача не найдена:
+//
+//	{
+//	  "error": "Task not found"
+//	}
+//
+// @Router       /task/{id}/events [get]
+func New(archiverService archiver.Archiver, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.task_events.New"
+
+	log = log.With("fn", fn)
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		events, unsubscribe, err := archiverService.Subscribe(taskID)
+		if err != nil {
+			switch {
+			case errors.Is(err, archiver.ErrServiceStopped):
+				c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskNotFound):
+				l.Warn(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+				return
+
+			default:
+				l.Error(err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+		defer unsubscribe()
+
+		l.Info("Task events subscribed", slog.String("task id", taskID))
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		// The status is read after subscribing, so no event falls between them
+		taskInfo, err := archiverService.GetStatus(taskID)
+		if err != nil {
+			return
+		}
+
+		c.SSEvent("status", get_status.BuildResponse(taskInfo))
+
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return false
+				}
+
+				c.SSEvent(string(ev.Type), newEvent(ev))
+
+				return true
+
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+
+		l.Info("Task events unsubscribed", slog.String("task id", taskID))
+	}
+}
+
+func newEvent(ev archiver.Event) Event {
+	out := Event{
+		Src:  sl.RedactURL(ev.Src),
+		Size: ev.Size,
+		Zip:  ev.Zip,
+	}
+
+	if ev.Object >= 0 {
+		object := ev.Object
+		out.Object = &object
+	}
+
+	if ev.Err != nil {
+		if ev.Type == archiver.EventTaskError {
+			out.Err = get_status.PrepareClientTaskErr(ev.Err)
+		} else {
+			out.Err = get_status.PrepareClientObjErr(ev.Err)
+		}
+	}
+
+	return out
+}
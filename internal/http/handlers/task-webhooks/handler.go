@@ -0,0 +1,152 @@
+package task_webhooks
+
+import (
+	"errors"
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/http/webhook"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type DeliveryLog interface {
+	Deliveries(taskID string) []webhook.Delivery
+}
+
+type Response struct {
+	CallbackURL string     `json:"callback_url,omitempty"`
+	Deliveries  []Delivery `json:"deliveries"`
+}
+
+type Delivery struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	TaskStatus string    `json:"task_status"`
+	Attempt    int       `json:"attempt"`
+	Time       time.Time `json:"time"`
+	DurationMs int64     `json:"duration_ms"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Err        string    `json:"error,omitempty"`
+}
+
+// New godoc
+// @Summary      Журнал доставки webhook-уведомлений задачи
+// @Description  Возвращает попытки доставки уведомлений о завершении задачи на callback_url, от старых к новым.
+// @Description  Журнал хранится в памяти и не переживает перезапуск сервиса.
+// @Tags         tasks
+// @Produce      json
+// @Param        id   path      string  true  "ID задачи"
+// @Success      200  {object}  Response  "Журнал доставки"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {json}  Успешный ответ:
+//
+//	{
+//	  "callback_url": "https://example.com/hooks/archive",
+//	  "deliveries": [
+//	    {
+//	      "id": "0f8e5f3c-5c55-4a43-9a43-0f7c1b3a2e11",
+//	      "url": "https://example.com/hooks/archive",
+//	      "task_status": "Done",
+//	      "attempt": 1,
+//	      "time": "2025-08-06T12:00:00Z",
+//	      "duration_ms": 120,
+//	      "status_code": 503,
+//	      "error": "unexpected status code: 503"
+//	    },
+//	    {
+//	      "id": "0f8e5f3c-5c55-4a43-9a43-0f7c1b3a2e11",
+//	      "url": "https://example.com/hooks/archive",
+//	      "task_status": "Done",
+//	      "attempt": 2,
+//	      "time": "2025-08-06T12:00:01Z",
+//	      "duration_ms": 95,
+//	      "status_code": 200
+//	    }
+//	  ]
+//	}
+//
+// @Example      {json}  Ошибка: Задача не найдена:
+//
+//	{
+//	  "error": "Task not found"
+//	}
+//
+// @Router       /task/{id}/webhooks [get]
+func New(archiverService archiver.Archiver, deliveryLog DeliveryLog, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.task_webhooks.New"
+
+	log = log.With("fn", fn)
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		taskInfo, err := archiverService.GetStatus(taskID)
+		if err != nil {
+			switch {
+			case errors.Is(err, archiver.ErrServiceStopped):
+				c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+				return
+
+			case errors.Is(err, archiver.ErrTaskNotFound):
+				l.Warn(err.Error(), slog.String("task id", taskID))
+
+				c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+				return
+
+			default:
+				l.Error(err.Error())
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+
+		deliveries := deliveryLog.Deliveries(taskID)
+
+		resp := Response{
+			CallbackURL: taskInfo.CallbackURL,
+			Deliveries:  make([]Delivery, 0, len(deliveries)),
+		}
+
+		for _, d := range deliveries {
+			var errMsg string
+			if d.Err != nil {
+				errMsg = d.Err.Error()
+			}
+
+			resp.Deliveries = append(resp.Deliveries, Delivery{
+				ID:         d.ID,
+				URL:        d.URL,
+				TaskStatus: d.TaskStatus,
+				Attempt:    d.Attempt,
+				Time:       d.Time,
+				DurationMs: d.Duration.Milliseconds(),
+				StatusCode: d.StatusCode,
+				Err:        errMsg,
+			})
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,380 @@
+package upload_objects
+
+import (
+	"context"
+	"errors"
+	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/fandasy/06.08.2025/internal/services/archiver/utils"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Spool keeps the uploaded files until the task is archived, e.g. utils.Uploads.
+type Spool interface {
+	Save(ctx context.Context, taskID, fileName, contentType string, content io.Reader) (string, error)
+	Remove(link string) error
+}
+
+type Response struct {
+	Added int `json:"added"`
+	// Replaced is the number of the earlier objects replaced under the overwrite name conflict policy
+	Replaced int    `json:"replaced,omitempty"`
+	Files    []File `json:"files,omitempty"`
+}
+
+type File struct {
+	Name string `json:"name"`
+	Err  string `json:"error,omitempty"`
+}
+
+// New godoc
+// @Summary      Загрузить файлы в задачу архивации
+// @Description  Принимает файлы в multipart/form-data и добавляет их в задачу наравне с объектами по URL.
+// @Description  Каждая часть с именем файла (filename) становится объектом задачи, имя файла — именем записи в архиве. Поле path задаёт папку внутри архива для следующих за ним файлов.
+// @Description  К файлам применяются те же правила, что и к URL: расширение проверяется по archiver.valid_extension, тип, определённый по содержимому файла, — по archive_object_getter.valid_content_type, размер — по archive_object_getter.max_object_size; файлы учитываются в max_objects.
+// @Description  Размер всего запроса ограничен archiver.upload.max_request_size (по умолчанию 1 ГиБ). Файлы пишутся во временный каталог archiver.upload.dir, а не в память, и удаляются после завершения, отмены или истечения задачи.
+// @Description  Совпадение имени с уже добавленным объектом обрабатывается по политике name_conflict задачи.
+// @Tags         tasks
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id    path      string  true   "ID задачи"
+// @Param        path  formData  string  false  "Папка внутри архива для следующих файлов"
+// @Param        file  formData  file    true   "Файл, может повторяться"
+// @Success      200  {object}  Response    "Файлы добавлены в задачу"
+// @Failure      400  {object}  response.ErrorResponse "Параметр taskID отсутствует"
+// @Failure      400  {object}  response.ErrorResponse "Тело запроса не multipart/form-data или повреждено ('request body is not valid')"
+// @Failure      400  {object}  response.ErrorResponse "Нет подходящих файлов ('no valid files')"
+// @Failure      409  {object}  response.ErrorResponse "Имя уже занято при политике fail ('object name already taken')"
+// @Failure      413  {object}  response.ErrorResponse "Запрос больше upload.max_request_size ('request body too large')"
+// @Failure      400  {object}  response.ErrorResponse "Задача уже в обработке ('Task is in progress')"
+// @Failure      400  {object}  response.ErrorResponse "Задача уже завершена ('Task is completed')"
+// @Failure      400  {object}  response.ErrorResponse "Задача отменена ('Task is canceled')"
+// @Failure      404  {object}  response.ErrorResponse "Задача не найдена ('Task not found')"
+// @Failure      410  {object}  response.ErrorResponse "Задача истекла ('Task expired')"
+// @Failure      503  {object}  response.ErrorResponse "Сервис архивации остановлен"
+// @Failure      500  {object}  response.ErrorResponse "Внутренняя ошибка сервера"
+// @Example      {json}  Успешный ответ:
+//
+//	{
+//	  "added": 1,
+//	  "files": [
+//	    {"name": "images/cover.jpeg"},
+//	    {"name": "notes.exe", "error": "invalid extension"}
+//	  ]
+//	}
+//
+// @Example      {json}  Ошибка: Нет подходящих файлов:
+//
+//	{
+//	  "error": "no valid files"
+//	}
+//
+// @Example      {json}  Ошибка: Задача не найдена:
+//
+//	{
+//	  "error": "Task not found"
+//	}
+//
+// @Router       /task/{id}/upload [post]
+func New(archiverService archiver.Archiver, spool Spool, validExtension []string, maxRequestSize int64, log *slog.Logger) gin.HandlerFunc {
+	const fn = "handlers.upload_objects.New"
+
+	log = log.With("fn", fn)
+
+	var validExtensionMap map[string]struct{}
+
+	if len(validExtension) > 0 {
+		validExtensionMap = make(map[string]struct{}, len(validExtension))
+		for _, ext := range validExtension {
+			validExtensionMap[ext] = struct{}{}
+		}
+	}
+
+	return func(c *gin.Context) {
+		l := log
+		if requestID, ok := c.Value(logger.RequestIDKey).(string); ok {
+			l = log.With("request id", requestID)
+		}
+
+		taskID := c.Param("id")
+		if taskID == "" {
+			l.Debug("Task ID missing in request parameters")
+
+			c.JSON(http.StatusBadRequest, response.Error("Task ID missing in request parameters"))
+
+			return
+		}
+
+		// Nothing is spooled for a task that can't take the files
+		info, err := archiverService.GetStatus(taskID)
+		if err == nil {
+			err = statusError(info.Status)
+		}
+		if err != nil {
+			writeError(c, l, taskID, err)
+			return
+		}
+
+		if maxRequestSize > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestSize)
+		}
+
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			l.Debug(err.Error())
+
+			c.JSON(http.StatusBadRequest, response.Error("request body is not valid"))
+
+			return
+		}
+
+		var resp Response
+		var objs []archiver.NewObject
+		var links []string
+
+		// The spooled files are removed unless the task takes them
+		defer func() {
+			for _, link := range links {
+				if err := spool.Remove(link); err != nil {
+					l.Error("Failed to remove uploaded file", slog.String("task id", taskID), sl.Err(err))
+				}
+			}
+		}()
+
+		var dir string
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeBodyError(c, l, err)
+				return
+			}
+
+			name := part.FileName()
+			if name == "" {
+				if part.FormName() == "path" {
+					value, err := io.ReadAll(io.LimitReader(part, maxPathLength+1))
+					if err != nil {
+						writeBodyError(c, l, err)
+						return
+					}
+					dir = strings.TrimSuffix(string(value), "/")
+				}
+
+				continue
+			}
+
+			entry := name
+			if dir != "" {
+				entry = dir + "/" + name
+			}
+
+			if err := fileValidate(dir, name, validExtensionMap); err != nil {
+				resp.Files = append(resp.Files, File{Name: entry, Err: err.Error()})
+				continue
+			}
+
+			body := &partReader{r: part}
+
+			link, err := spool.Save(c.Request.Context(), taskID, name, part.Header.Get("Content-Type"), body)
+			if err != nil {
+				switch {
+				case body.err != nil:
+					writeBodyError(c, l, body.err)
+					return
+
+				case errors.Is(err, utils.ErrIncorrectFormat):
+					resp.Files = append(resp.Files, File{Name: entry, Err: ErrIncorrectFormat.Error()})
+					continue
+
+				case errors.Is(err, utils.ErrFileTooLarge):
+					resp.Files = append(resp.Files, File{Name: entry, Err: ErrFileTooLarge.Error()})
+					continue
+
+				default:
+					l.Error(err.Error())
+
+					c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+					return
+				}
+			}
+
+			links = append(links, link)
+			resp.Files = append(resp.Files, File{Name: entry})
+			objs = append(objs, archiver.NewObject{URL: link, Name: name, Path: dir})
+		}
+
+		if len(objs) == 0 {
+			l.Debug("No valid files")
+
+			c.JSON(http.StatusBadRequest, response.Error("no valid files"))
+
+			return
+		}
+
+		result, err := archiverService.AddObjects(taskID, objs)
+		if err != nil {
+			writeError(c, l, taskID, err)
+			return
+		}
+
+		// The files over MaxObjects are not added
+		accepted := result.Accepted()
+		links = links[accepted:]
+
+		// Neither are kept the files replaced under the overwrite policy, the earlier uploads or the ones of this request
+		for _, src := range result.Replaced {
+			if utils.IsUploadLink(src) {
+				links = append(links, src)
+			}
+		}
+
+		if accepted < len(objs) {
+			validCount := 0
+			for i := range resp.Files {
+				if resp.Files[i].Err == "" {
+					validCount++
+					if validCount > accepted {
+						resp.Files[i].Err = ErrNoMorePlacesAvailable.Error()
+					}
+				}
+			}
+		}
+
+		resp.Added = result.Added
+		resp.Replaced = len(result.Replaced)
+
+		l.Info("Files successfully uploaded to task", slog.String("task id", taskID), slog.Any("files", resp.Files))
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+var (
+	ErrInvalidName           = errors.New("invalid name or path")
+	ErrInvalidExtension      = errors.New("invalid extension")
+	ErrIncorrectFormat       = errors.New("incorrect format")
+	ErrFileTooLarge          = errors.New("file too large")
+	ErrNoMorePlacesAvailable = errors.New("no more places available")
+)
+
+// maxPathLength limits the path field
+const maxPathLength = 1024
+
+func fileValidate(dir, name string, valid map[string]struct{}) error {
+	if len(dir) > maxPathLength {
+		return ErrInvalidName
+	}
+
+	if err := archiver.ValidateEntry(dir, name); err != nil {
+		return ErrInvalidName
+	}
+
+	if valid != nil {
+		if _, ok := valid[filepath.Ext(name)]; !ok {
+			return ErrInvalidExtension
+		}
+	}
+
+	return nil
+}
+
+// statusError is the error AddObjects would return for a task in the status.
+func statusError(status archiver.TaskStatus) error {
+	switch status {
+	case archiver.StatusWaitingForObjects:
+		return nil
+	case archiver.StatusArchiving:
+		return archiver.ErrTaskInProgress
+	case archiver.StatusExpired:
+		return archiver.ErrTaskExpired
+	case archiver.StatusCanceled:
+		return archiver.ErrTaskCanceled
+	}
+
+	return archiver.ErrTaskCompleted
+}
+
+// partReader remembers the error of reading the request, to tell it from an error of the spool.
+type partReader struct {
+	r   *multipart.Part
+	err error
+}
+
+func (p *partReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+
+	return n, err
+}
+
+func writeBodyError(c *gin.Context, log *slog.Logger, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		log.Debug(err.Error())
+
+		c.JSON(http.StatusRequestEntityTooLarge, response.Error("request body too large"))
+
+		return
+	}
+
+	log.Debug(err.Error())
+
+	c.JSON(http.StatusBadRequest, response.Error("request body is not valid"))
+}
+
+func writeError(c *gin.Context, log *slog.Logger, taskID string, err error) {
+	switch {
+	case errors.Is(err, archiver.ErrServiceStopped):
+		c.JSON(http.StatusServiceUnavailable, response.Error("Archiver service is stopped"))
+
+	case errors.Is(err, archiver.ErrNameConflict):
+		log.Info(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusConflict, response.Error("object name already taken"))
+
+	case errors.Is(err, archiver.ErrTaskNotFound):
+		log.Warn(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusNotFound, response.Error("Task not found"))
+
+	case errors.Is(err, archiver.ErrTaskInProgress):
+		log.Info(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusBadRequest, response.Error("Task is in progress"))
+
+	case errors.Is(err, archiver.ErrTaskCompleted):
+		log.Info(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusBadRequest, response.Error("Task is completed"))
+
+	case errors.Is(err, archiver.ErrTaskCanceled):
+		log.Info(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusBadRequest, response.Error("Task is canceled"))
+
+	case errors.Is(err, archiver.ErrTaskExpired):
+		log.Info(err.Error(), slog.String("task id", taskID))
+
+		c.JSON(http.StatusGone, response.Error("Task expired"))
+
+	default:
+		log.Error(err.Error())
+
+		c.JSON(http.StatusInternalServerError, response.InternalServerError())
+	}
+}
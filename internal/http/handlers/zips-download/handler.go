@@ -1,31 +1,48 @@
 package zips_download
 
 import (
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
 	"github.com/fandasy/06.08.2025/internal/http/middlewares/logger"
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
 	"github.com/fandasy/06.08.2025/internal/pkg/api/response"
+	signed_link "github.com/fandasy/06.08.2025/internal/pkg/signed-link"
 	"github.com/gin-gonic/gin"
-	"log/slog"
-	"net/http"
-	"os"
-	"path/filepath"
 )
 
 // New godoc
-// @Summary      Скачать готовый ZIP-архив
-// @Description  Возвращает готовый ZIP-архив задачи по имени файла. Если файл не найден — возвращает ошибку.
+// @Summary      Скачать готовый архив
+// @Description  Возвращает готовый архив задачи по имени файла, Content-Type соответствует формату (zip, tar, tar.gz, tar.zst). Если файл не найден — возвращает ошибку. Поддерживаются Range-запросы.
+// @Description  Если задан local_zip_storage.link_secret, ссылка должна содержать действительную подпись и не истёкший срок действия.
 // @Tags         zips
-// @Produce      application/zip
-// @Param        filename   path      string  true  "Имя ZIP-файла"
-// @Success      200        {file}    file    "ZIP-архив для скачивания"
+// @Produce      application/zip,application/x-tar,application/gzip,application/zstd
+// @Param        filename   path      string  true   "Имя файла архива"
+// @Param        expires    query     int     false  "Срок действия ссылки, Unix-время"
+// @Param        signature  query     string  false  "HMAC-SHA256 подпись имени файла и срока действия"
+// @Success      200        {file}    file    "Архив для скачивания"
+// @Success      206        {file}    file    "Запрошенная часть архива"
+// @Failure      403        {object}  response.ErrorResponse "Неверная подпись ссылки ('Invalid link signature')"
 // @Failure      404        {object}  response.ErrorResponse "Файл не найден"
+// @Failure      410        {object}  response.ErrorResponse "Срок действия ссылки истёк ('Link expired')"
+// @Failure      500        {object}  response.ErrorResponse "Внутренняя ошибка сервера"
 // @Example      {json}  Ошибка: Файл не найден:
 //
 //	{
 //	  "error": "File not found"
 //	}
 //
+// @Example      {json}  Ошибка: Срок действия ссылки истёк:
+//
+//	{
+//	  "error": "Link expired"
+//	}
+//
 // @Router       /zips/{filename} [get]
-func New(zipsDir string, log *slog.Logger) gin.HandlerFunc {
+func New(objects object_storage.ObjectStorage, links *signed_link.Signer, log *slog.Logger) gin.HandlerFunc {
 	const fn = "handlers.zips_download.New"
 
 	log = log.With("fn", fn)
@@ -38,18 +55,57 @@ func New(zipsDir string, log *slog.Logger) gin.HandlerFunc {
 
 		filename := c.Param("filename")
 
-		filePath := filepath.Join(zipsDir, filepath.Base(filename))
+		if links != nil {
+			if err := links.Verify(filename, c.Request.URL.Query(), time.Now()); err != nil {
+				switch {
+				case errors.Is(err, signed_link.ErrLinkExpired):
+					log.Info(err.Error(), slog.String("filename", filename))
+
+					c.JSON(http.StatusGone, response.Error("Link expired"))
 
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			log.Warn("File not found", slog.String("filename", filename))
+					return
 
-			c.JSON(http.StatusNotFound, response.Error("File not found"))
+				default:
+					log.Warn(err.Error(), slog.String("filename", filename))
+
+					c.JSON(http.StatusForbidden, response.Error("Invalid link signature"))
+
+					return
+				}
+			}
+		}
+
+		obj, err := objects.Open(c.Request.Context(), filename)
+		if err != nil {
+			switch {
+			case errors.Is(err, object_storage.ErrObjectNotFound),
+				errors.Is(err, object_storage.ErrInvalidName):
+				log.Warn("File not found", slog.String("filename", filename))
+
+				c.JSON(http.StatusNotFound, response.Error("File not found"))
+
+				return
+
+			default:
+				log.Error(err.Error(), slog.String("filename", filename))
+
+				c.JSON(http.StatusInternalServerError, response.InternalServerError())
+
+				return
+			}
+		}
+		defer obj.Content.Close()
 
-			return
+		// Archives written before the formats were added have no extension
+		format := object_storage.FormatOf(obj.Name)
+		attachment := obj.Name
+		if format == "" {
+			attachment += object_storage.FormatZip.Extension()
 		}
 
-		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Type", format.ContentType())
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment}))
 
-		c.FileAttachment(filePath, filename)
+		http.ServeContent(c.Writer, c.Request, obj.Name, obj.ModTime, obj.Content)
 	}
 }
@@ -8,7 +8,7 @@ import (
 func Middleware() gin.HandlerFunc {
 	fn := func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type")
 
 		if c.Request.Method == http.MethodOptions {
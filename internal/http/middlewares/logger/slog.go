@@ -5,11 +5,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"log/slog"
+	"net/url"
 	"time"
 )
 
 const RequestIDKey = "request-id-key"
 
+// secretParams are query parameters whose values are never logged
+var secretParams = []string{"password"}
+
 func Middleware(log *slog.Logger) gin.HandlerFunc {
 	fn := func(c *gin.Context) {
 
@@ -32,7 +36,7 @@ func Middleware(log *slog.Logger) gin.HandlerFunc {
 		StatusCode := c.Writer.Status()
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + redactQuery(raw)
 		}
 
 		log.Info("[SLOG]",
@@ -47,6 +51,29 @@ func Middleware(log *slog.Logger) gin.HandlerFunc {
 	return fn
 }
 
+// redactQuery replaces the values of secretParams in the raw query.
+func redactQuery(raw string) string {
+	query, err := url.ParseQuery(raw)
+	if err != nil {
+		// The query can't be redacted reliably
+		return "[REDACTED]"
+	}
+
+	redacted := false
+	for _, param := range secretParams {
+		if _, ok := query[param]; ok {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+
+	if !redacted {
+		return raw
+	}
+
+	return query.Encode()
+}
+
 func newID() string {
 	var id string
 
@@ -0,0 +1,389 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	get_status "github.com/fandasy/06.08.2025/internal/http/handlers/get-status"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderAttempt   = "X-Webhook-Attempt"
+	HeaderTaskID    = "X-Task-Id"
+)
+
+var (
+	ErrQueueFull      = errors.New("webhook queue is full")
+	ErrUnexpectedCode = errors.New("unexpected status code")
+)
+
+type Config struct {
+	// Secret is the HMAC-SHA256 key of the signature header, the payload is not signed if empty.
+	Secret string
+
+	// Timeout of one delivery attempt.
+	Timeout time.Duration
+	// MaxAttempts is the total number of attempts of one notification.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt, doubled up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Workers is the number of notifications delivered at the same time.
+	Workers int
+	// QueueSize is the number of notifications waiting for a worker.
+	QueueSize int
+
+	// LogRetention is how long the delivery log of a task is kept after its last delivery.
+	LogRetention time.Duration
+	// LogSize is the maximum number of attempts kept in the delivery log of one task.
+	LogSize int
+}
+
+// Delivery is one attempt to deliver a notification.
+type Delivery struct {
+	// ID is the same for all attempts of one notification
+	ID         string
+	URL        string
+	TaskStatus string
+	Attempt    int
+	Time       time.Time
+	Duration   time.Duration
+	// StatusCode is 0 if no response was received
+	StatusCode int
+	Err        error
+}
+
+// Dispatcher delivers task notifications to callback URLs:
+//   - the payload has the shape of get_status.Response
+//   - failed attempts (network errors, 408, 429 and 5xx) are retried with exponential backoff
+//   - every attempt is written to the in-memory delivery log of the task
+type Dispatcher struct {
+	cfg    Config
+	client *http.Client
+
+	queue chan notification
+
+	mu       sync.Mutex
+	journals map[string]*journal
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+
+	log *slog.Logger
+}
+
+type notification struct {
+	id         string
+	taskID     string
+	url        string
+	taskStatus string
+	payload    []byte
+}
+
+type journal struct {
+	deliveries []Delivery
+	updatedAt  time.Time
+}
+
+func New(client *http.Client, cfg Config, log *slog.Logger) *Dispatcher {
+	cfg.validate()
+
+	d := &Dispatcher{
+		cfg:      cfg,
+		client:   client,
+		queue:    make(chan notification, cfg.QueueSize),
+		journals: make(map[string]*journal),
+		stopCh:   make(chan struct{}),
+		log:      log,
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+
+	return d
+}
+
+const (
+	defaultTimeout        = 10 * time.Second
+	defaultMaxAttempts    = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultWorkers        = 4
+	defaultQueueSize      = 1024
+	defaultLogRetention   = 24 * time.Hour
+	defaultLogSize        = 50
+)
+
+func (cfg *Config) validate() {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.LogRetention <= 0 {
+		cfg.LogRetention = defaultLogRetention
+	}
+	if cfg.LogSize <= 0 {
+		cfg.LogSize = defaultLogSize
+	}
+}
+
+// Notify queues the notification, it implements archiver.Notifier.
+func (d *Dispatcher) Notify(taskID string, callbackURL string, info *archiver.TaskInfo) {
+	n := notification{
+		id:         uuid.NewString(),
+		taskID:     taskID,
+		url:        callbackURL,
+		taskStatus: info.Status.String(),
+	}
+
+	payload, err := json.Marshal(get_status.BuildResponse(info))
+	if err != nil {
+		d.log.Error("Failed to marshal webhook payload", slog.String("task id", taskID), sl.Err(err))
+		return
+	}
+	n.payload = payload
+
+	select {
+	case <-d.stopCh:
+		return
+	default:
+	}
+
+	select {
+	case d.queue <- n:
+	default:
+		d.log.Error("Webhook dropped", slog.String("task id", taskID), sl.Err(ErrQueueFull))
+
+		d.record(n, Delivery{Time: time.Now(), Err: ErrQueueFull})
+	}
+}
+
+// Deliveries returns the delivery log of the task, oldest first.
+func (d *Dispatcher) Deliveries(taskID string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	j, ok := d.journals[taskID]
+	if !ok {
+		return nil
+	}
+
+	out := make([]Delivery, len(j.deliveries))
+	copy(out, j.deliveries)
+
+	return out
+}
+
+// Stop refuses new notifications and waits until the queued ones get their last attempt,
+// retries are not made after the stop.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stopCh) })
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case n := <-d.queue:
+			d.deliver(n)
+		case <-d.stopCh:
+			for {
+				select {
+				case n := <-d.queue:
+					d.deliver(n)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver makes up to MaxAttempts attempts, it gives up when the dispatcher stops.
+func (d *Dispatcher) deliver(n notification) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.log.Error("Panic recovered", slog.String("stack", string(debug.Stack())))
+		}
+	}()
+
+	for attempt := 1; ; attempt++ {
+		delivery, retry := d.send(n, attempt)
+		d.record(n, delivery)
+
+		if delivery.Err == nil {
+			d.log.Info("Webhook delivered",
+				slog.String("task id", n.taskID), slog.Int("attempt", attempt))
+			return
+		}
+
+		if !retry || attempt >= d.cfg.MaxAttempts {
+			d.log.Error("Webhook delivery failed",
+				slog.String("task id", n.taskID), slog.Int("attempt", attempt), sl.Err(delivery.Err))
+			return
+		}
+
+		timer := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-d.stopCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt, retry is false if the failure is permanent.
+func (d *Dispatcher) send(n notification, attempt int) (delivery Delivery, retry bool) {
+	delivery = Delivery{Attempt: attempt, Time: time.Now()}
+	defer func() { delivery.Duration = time.Since(delivery.Time) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(n.payload))
+	if err != nil {
+		delivery.Err = fmt.Errorf("new request failed: %w", err)
+		return delivery, false
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, n.id)
+	req.Header.Set(HeaderAttempt, strconv.Itoa(attempt))
+	req.Header.Set(HeaderTaskID, n.taskID)
+	if d.cfg.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(d.cfg.Secret, n.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		delivery.Err = fmt.Errorf("request failed: %w", err)
+		return delivery, true
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	delivery.StatusCode = resp.StatusCode
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return delivery, false
+	}
+
+	delivery.Err = fmt.Errorf("%w: %d", ErrUnexpectedCode, resp.StatusCode)
+
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return delivery, true
+	}
+
+	return delivery, false
+}
+
+// backoff doubles the delay for every attempt and subtracts a random jitter of up to half of it.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= d.cfg.MaxBackoff {
+			delay = d.cfg.MaxBackoff
+			break
+		}
+	}
+
+	return delay - rand.N(delay/2+1)
+}
+
+// record appends the attempt to the delivery log of the task
+// and drops the logs not updated for LogRetention.
+func (d *Dispatcher) record(n notification, delivery Delivery) {
+	delivery.ID = n.id
+	delivery.URL = n.url
+	delivery.TaskStatus = n.taskStatus
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+
+	for id, j := range d.journals {
+		if now.Sub(j.updatedAt) > d.cfg.LogRetention {
+			delete(d.journals, id)
+		}
+	}
+
+	j, ok := d.journals[n.taskID]
+	if !ok {
+		j = &journal{}
+		d.journals[n.taskID] = j
+	}
+
+	j.deliveries = append(j.deliveries, delivery)
+	if len(j.deliveries) > d.cfg.LogSize {
+		j.deliveries = j.deliveries[len(j.deliveries)-d.cfg.LogSize:]
+	}
+	j.updatedAt = now
+}
+
+// Sign returns the value of the signature header: "sha256=" + hex(HMAC-SHA256(secret, payload)).
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	get_status "github.com/fandasy/06.08.2025/internal/http/handlers/get-status"
+	"github.com/fandasy/06.08.2025/internal/services/archiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDispatcher(secret string) *Dispatcher {
+	return New(http.DefaultClient, Config{
+		Secret:         secret,
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}, slog.Default())
+}
+
+func waitDeliveries(t *testing.T, d *Dispatcher, taskID string, n int) []Delivery {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return len(d.Deliveries(taskID)) >= n
+	}, 3*time.Second, 10*time.Millisecond)
+
+	return d.Deliveries(taskID)
+}
+
+func TestNotifySigned(t *testing.T) {
+	const secret = "secret"
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher(secret)
+	defer d.Stop(context.Background())
+
+	d.Notify("task-1", server.URL, &archiver.TaskInfo{
+		Status: archiver.StatusDone,
+		Objects: []archiver.ObjectInfo{
+			{Src: "https://example.com/file.pdf", Attempts: 1},
+		},
+		Zip: "http://localhost:8080/zips/task-1.zip",
+	})
+
+	r := <-received
+	body := <-bodies
+
+	assert.Equal(t, http.MethodPost, r.Method)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "task-1", r.Header.Get(HeaderTaskID))
+	assert.Equal(t, "1", r.Header.Get(HeaderAttempt))
+	assert.Equal(t, Sign(secret, body), r.Header.Get(HeaderSignature))
+
+	var resp get_status.Response
+	require.NoError(t, json.Unmarshal(body, &resp))
+	assert.Equal(t, "Done", resp.Status)
+	assert.Equal(t, "http://localhost:8080/zips/task-1.zip", resp.Zip)
+	require.Len(t, resp.Objects, 1)
+
+	deliveries := waitDeliveries(t, d, "task-1", 1)
+	assert.NoError(t, deliveries[0].Err)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	assert.Equal(t, r.Header.Get(HeaderDelivery), deliveries[0].ID)
+}
+
+func TestNotifyRetries(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	d := newTestDispatcher("")
+	defer d.Stop(context.Background())
+
+	info := &archiver.TaskInfo{Status: archiver.StatusError}
+
+	t.Run("server errors are retried", func(t *testing.T) {
+		d.Notify("flaky", server.URL+"/flaky", info)
+
+		deliveries := waitDeliveries(t, d, "flaky", 3)
+		require.Len(t, deliveries, 3)
+
+		for i, delivery := range deliveries {
+			assert.Equal(t, i+1, delivery.Attempt)
+			assert.Equal(t, deliveries[0].ID, delivery.ID)
+			assert.Equal(t, "Error", delivery.TaskStatus)
+		}
+
+		assert.ErrorIs(t, deliveries[0].Err, ErrUnexpectedCode)
+		assert.Equal(t, http.StatusServiceUnavailable, deliveries[0].StatusCode)
+		assert.NoError(t, deliveries[2].Err)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		d.Notify("rejected", server.URL+"/rejected", info)
+
+		deliveries := waitDeliveries(t, d, "rejected", 1)
+
+		// Give a retry the time to show up
+		time.Sleep(50 * time.Millisecond)
+		deliveries = d.Deliveries("rejected")
+
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, http.StatusBadRequest, deliveries[0].StatusCode)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		d.Notify("unreachable", closed.URL, info)
+
+		deliveries := waitDeliveries(t, d, "unreachable", 3)
+
+		time.Sleep(50 * time.Millisecond)
+		require.Len(t, d.Deliveries("unreachable"), 3)
+		assert.Error(t, deliveries[2].Err)
+		assert.Zero(t, deliveries[2].StatusCode)
+	})
+}
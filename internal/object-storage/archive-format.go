@@ -0,0 +1,103 @@
+package object_storage
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnknownFormat          = errors.New("unknown archive format")
+	ErrEncryptionNotSupported = errors.New("archive format does not support encryption")
+)
+
+// ArchiveOptions are chosen per archive.
+type ArchiveOptions struct {
+	Format ArchiveFormat
+	// Password encrypts the entries with WinZip AES-256, only FormatZip supports it.
+	// It must never be logged or persisted.
+	Password string
+}
+
+// Validate return error:
+//   - ErrUnknownFormat
+//   - ErrEncryptionNotSupported
+func (o ArchiveOptions) Validate() error {
+	switch o.Format {
+	case "", FormatZip:
+		return nil
+
+	case FormatTar, FormatTarGz, FormatTarZst:
+		if o.Password != "" {
+			return ErrEncryptionNotSupported
+		}
+		return nil
+
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// ArchiveFormat is the container and the compression of an archive.
+// The zero format is a zip archive named without an extension,
+// the archives written before the formats were added are kept this way.
+type ArchiveFormat string
+
+const (
+	FormatZip    ArchiveFormat = "zip"
+	FormatTar    ArchiveFormat = "tar"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarZst ArchiveFormat = "tar.zst"
+)
+
+// formats are ordered so that the longest extension is matched first.
+var formats = []ArchiveFormat{FormatTarGz, FormatTarZst, FormatTar, FormatZip}
+
+// ParseArchiveFormat accepts the formats by name, an empty name is FormatZip.
+// Return error:
+//   - ErrUnknownFormat
+func ParseArchiveFormat(name string) (ArchiveFormat, error) {
+	if name == "" {
+		return FormatZip, nil
+	}
+
+	for _, f := range formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// FormatOf returns the format of the archive by its file name.
+func FormatOf(filename string) ArchiveFormat {
+	for _, f := range formats {
+		if strings.HasSuffix(filename, f.Extension()) {
+			return f
+		}
+	}
+
+	return ""
+}
+
+// Extension returns the file extension with the leading dot, empty for the zero format.
+func (f ArchiveFormat) Extension() string {
+	if f == "" {
+		return ""
+	}
+
+	return "." + string(f)
+}
+
+func (f ArchiveFormat) ContentType() string {
+	switch f {
+	case FormatTar:
+		return "application/x-tar"
+	case FormatTarGz:
+		return "application/gzip"
+	case FormatTarZst:
+		return "application/zstd"
+	default:
+		return "application/zip"
+	}
+}
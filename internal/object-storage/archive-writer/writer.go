@@ -0,0 +1,196 @@
+package archive_writer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/pkg/e"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer writes the entries of one archive into the underlying stream.
+type Writer interface {
+	// WriteObject reads the content of the object until EOF into a new entry.
+	// If reading fails, the entry is left truncated, but the archive stays valid.
+	WriteObject(obj *object_storage.ArchiveObject) error
+	// Close writes the end of the archive, it does not close the underlying stream.
+	Close() error
+}
+
+// New returns the writer of the format, the zero format is zip.
+// Return error:
+//   - object_storage.ErrUnknownFormat
+//   - object_storage.ErrEncryptionNotSupported
+func New(w io.Writer, opts object_storage.ArchiveOptions) (Writer, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch opts.Format {
+	case object_storage.FormatZip, "":
+		return &zipWriter{w: zip.NewWriter(w), password: []byte(opts.Password)}, nil
+
+	case object_storage.FormatTar:
+		return newTarWriter(w, nil), nil
+
+	case object_storage.FormatTarGz:
+		gz := gzip.NewWriter(w)
+		return newTarWriter(gz, gz), nil
+
+	case object_storage.FormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, e.Wrap("archive-writer.zstd.NewWriter", err)
+		}
+		return newTarWriter(zw, zw), nil
+
+	default:
+		return nil, object_storage.ErrUnknownFormat
+	}
+}
+
+type zipWriter struct {
+	w *zip.Writer
+	// password encrypts the entries if not empty
+	password []byte
+}
+
+func (z *zipWriter) WriteObject(obj *object_storage.ArchiveObject) error {
+	if len(z.password) > 0 {
+		return z.writeEncrypted(obj)
+	}
+
+	header := &zip.FileHeader{
+		Name:     obj.Name,
+		Method:   zip.Deflate,
+		Modified: obj.Time,
+	}
+
+	writer, err := z.w.CreateHeader(header)
+	if err != nil {
+		return e.Wrap("archive-writer.zip.CreateHeader", err)
+	}
+
+	if _, err := io.Copy(writer, obj.Content); err != nil {
+		return e.Wrap("archive-writer.io.Copy", err)
+	}
+
+	return nil
+}
+
+func (z *zipWriter) Close() error {
+	return e.Wrap("archive-writer.zip.Close", z.w.Close())
+}
+
+// tarWriter needs the size of an entry before its content:
+//   - objects of a known size are streamed, a short read is padded with zeros
+//   - objects of an unknown size are spooled into a temporary file first
+type tarWriter struct {
+	w *tar.Writer
+	// compressor is closed after the tar stream, nil for a plain tar
+	compressor io.WriteCloser
+}
+
+func newTarWriter(w io.Writer, compressor io.WriteCloser) *tarWriter {
+	return &tarWriter{
+		w:          tar.NewWriter(w),
+		compressor: compressor,
+	}
+}
+
+func (t *tarWriter) WriteObject(obj *object_storage.ArchiveObject) error {
+	if obj.Size < 0 {
+		return t.writeSpooled(obj)
+	}
+
+	if err := t.writeHeader(obj, obj.Size); err != nil {
+		return err
+	}
+
+	n, err := io.Copy(t.w, io.LimitReader(obj.Content, obj.Size))
+	if err == nil && n < obj.Size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		if padErr := t.pad(obj.Size - n); padErr != nil {
+			return padErr
+		}
+		return e.Wrap("archive-writer.io.Copy", err)
+	}
+
+	return nil
+}
+
+func (t *tarWriter) writeSpooled(obj *object_storage.ArchiveObject) error {
+	spool, err := os.CreateTemp("", "archive-entry-*")
+	if err != nil {
+		return e.Wrap("archive-writer.os.CreateTemp", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, readErr := io.Copy(spool, obj.Content)
+	// A broken source is reported after the part read so far is written
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return e.Wrap("archive-writer.file.Seek", err)
+	}
+
+	if err := t.writeHeader(obj, size); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(t.w, spool); err != nil {
+		return e.Wrap("archive-writer.io.Copy", err)
+	}
+
+	return e.Wrap("archive-writer.io.Copy", readErr)
+}
+
+func (t *tarWriter) writeHeader(obj *object_storage.ArchiveObject, size int64) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     obj.Name,
+		Size:     size,
+		Mode:     0644,
+		ModTime:  obj.Time,
+		Format:   tar.FormatPAX,
+	}
+
+	return e.Wrap("archive-writer.tar.WriteHeader", t.w.WriteHeader(header))
+}
+
+// pad fills the rest of a truncated entry with zeros.
+func (t *tarWriter) pad(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+
+	_, err := io.CopyN(t.w, zeros{}, n)
+
+	return e.Wrap("archive-writer.pad", err)
+}
+
+func (t *tarWriter) Close() error {
+	err := t.w.Close()
+
+	if t.compressor != nil {
+		err = errors.Join(err, t.compressor.Close())
+	}
+
+	return e.Wrap("archive-writer.tar.Close", err)
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
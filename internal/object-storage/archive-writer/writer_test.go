@@ -0,0 +1,254 @@
+package archive_writer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+var errBroken = errors.New("broken source")
+
+// writeObjects writes a known size, an unknown size and a broken object.
+func writeObjects(t *testing.T, format object_storage.ArchiveFormat) []byte {
+	var buf bytes.Buffer
+
+	w, err := New(&buf, object_storage.ArchiveOptions{Format: format})
+	require.NoError(t, err)
+
+	require.NoError(t, w.WriteObject(&object_storage.ArchiveObject{
+		Name:    "known.txt",
+		Time:    time.Now(),
+		Size:    5,
+		Content: io.NopCloser(strings.NewReader("known")),
+	}))
+
+	require.NoError(t, w.WriteObject(&object_storage.ArchiveObject{
+		Name:    "unknown.txt",
+		Time:    time.Now(),
+		Size:    -1,
+		Content: io.NopCloser(strings.NewReader("unknown")),
+	}))
+
+	err = w.WriteObject(&object_storage.ArchiveObject{
+		Name:    "broken.txt",
+		Time:    time.Now(),
+		Size:    10,
+		Content: io.NopCloser(io.MultiReader(strings.NewReader("bro"), iotest.ErrReader(errBroken))),
+	})
+	require.ErrorIs(t, err, errBroken)
+
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	out := make(map[string]string)
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+
+		out[header.Name] = string(data)
+	}
+
+	return out
+}
+
+func TestTarFormats(t *testing.T) {
+	expected := map[string]string{
+		"known.txt":   "known",
+		"unknown.txt": "unknown",
+		// A broken entry keeps its declared size, the rest is zeros
+		"broken.txt": "bro\x00\x00\x00\x00\x00\x00\x00",
+	}
+
+	data := writeObjects(t, object_storage.FormatTar)
+	require.Equal(t, expected, readTar(t, bytes.NewReader(data)))
+
+	data = writeObjects(t, object_storage.FormatTarGz)
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, expected, readTar(t, gz))
+
+	data = writeObjects(t, object_storage.FormatTarZst)
+	zr, err := zstd.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer zr.Close()
+	require.Equal(t, expected, readTar(t, zr))
+}
+
+func TestZipFormat(t *testing.T) {
+	data := writeObjects(t, object_storage.FormatZip)
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, r.File, 3)
+
+	rc, err := r.File[1].Open()
+	require.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "unknown", string(content))
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := New(io.Discard, object_storage.ArchiveOptions{Format: "rar"})
+	require.ErrorIs(t, err, object_storage.ErrUnknownFormat)
+}
+
+func TestFormatOf(t *testing.T) {
+	require.Equal(t, object_storage.FormatTarGz, object_storage.FormatOf("id.tar.gz"))
+	require.Equal(t, object_storage.FormatTar, object_storage.FormatOf("id.tar"))
+	require.Equal(t, object_storage.ArchiveFormat(""), object_storage.FormatOf("id"))
+
+	format, err := object_storage.ParseArchiveFormat("TAR.ZST")
+	require.NoError(t, err)
+	require.Equal(t, ".tar.zst", format.Extension())
+	require.Equal(t, "application/zstd", format.ContentType())
+}
+
+// decryptAES opens an entry of a WinZip AES-256 archive.
+func decryptAES(t *testing.T, f *zip.File, password string) string {
+	require.EqualValues(t, methodWinZipAES, f.Method)
+	require.Equal(t, aesExtra(zip.Deflate), f.Extra)
+
+	rc, err := f.OpenRaw()
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+
+	salt, verifier := data[:aesSaltLen], data[aesSaltLen:aesSaltLen+aesVerifyLen]
+	encrypted, auth := data[aesSaltLen+aesVerifyLen:len(data)-aesAuthLen], data[len(data)-aesAuthLen:]
+
+	keys, err := pbkdf2.Key(sha1.New, password, salt, aesIterations, 2*aesKeyLen+aesVerifyLen)
+	require.NoError(t, err)
+	require.Equal(t, keys[2*aesKeyLen:], verifier)
+
+	mac := hmac.New(sha1.New, keys[aesKeyLen:2*aesKeyLen])
+	mac.Write(encrypted)
+	require.Equal(t, mac.Sum(nil)[:aesAuthLen], auth)
+
+	block, err := aes.NewCipher(keys[:aesKeyLen])
+	require.NoError(t, err)
+
+	// Decrypted independently of ctrWriter: block i is XORed with AES(i), i is little-endian and starts at 1
+	plain := make([]byte, len(encrypted))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(encrypted); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+		block.Encrypt(stream[:], counter[:])
+
+		for j := i; j < min(i+aes.BlockSize, len(encrypted)); j++ {
+			plain[j] = encrypted[j] ^ stream[j-i]
+		}
+	}
+
+	content, err := io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestEncryptedZip(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := New(&buf, object_storage.ArchiveOptions{Format: object_storage.FormatZip, Password: "secret"})
+	require.NoError(t, err)
+
+	long := strings.Repeat("confidential ", 1000)
+
+	require.NoError(t, w.WriteObject(&object_storage.ArchiveObject{
+		Name:    "long.txt",
+		Time:    time.Now(),
+		Size:    -1,
+		Content: io.NopCloser(strings.NewReader(long)),
+	}))
+
+	err = w.WriteObject(&object_storage.ArchiveObject{
+		Name:    "broken.txt",
+		Time:    time.Now(),
+		Size:    -1,
+		Content: io.NopCloser(io.MultiReader(strings.NewReader("bro"), iotest.ErrReader(errBroken))),
+	})
+	require.ErrorIs(t, err, errBroken)
+
+	require.NoError(t, w.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, r.File, 2)
+
+	require.Equal(t, long, decryptAES(t, r.File[0], "secret"))
+	require.EqualValues(t, len(long), r.File[0].UncompressedSize64)
+	require.Equal(t, "bro", decryptAES(t, r.File[1], "secret"))
+
+	// The plain text is not stored
+	require.NotContains(t, buf.String(), "confidential")
+
+	_, err = New(io.Discard, object_storage.ArchiveOptions{Format: object_storage.FormatTarGz, Password: "secret"})
+	require.ErrorIs(t, err, object_storage.ErrEncryptionNotSupported)
+}
+
+// TestZipAESKnownAnswer checks the key derivation and the encryption against an entry written by libarchive 3.7.7:
+// bsdtar -cf fox.zip --format zip --options zip:encryption=aes256,zip:compression=store --passphrase secret fox.txt
+func TestZipAESKnownAnswer(t *testing.T) {
+	const (
+		password   = "secret"
+		plain      = "The quick brown fox jumps over the lazy dog."
+		salt       = "60c8ef5bf4141f721d1f5258c2dbfa81"
+		verifier   = "69fa"
+		ciphertext = "24db92785a3c1d53213b3915e8913cbdafdf0d07be5d66ebe62a15bdfeb5e9295ee7e0badc6a709badc62d98"
+		auth       = "8faf44aeba8647abeeef"
+	)
+
+	saltBytes, err := hex.DecodeString(salt)
+	require.NoError(t, err)
+
+	encKey, macKey, verify, err := aesKeys([]byte(password), saltBytes)
+	require.NoError(t, err)
+	require.Equal(t, verifier, hex.EncodeToString(verify))
+
+	block, err := aes.NewCipher(encKey)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	mac := hmac.New(sha1.New, macKey)
+	enc := &ctrWriter{block: block, w: &out, mac: mac}
+
+	// Written in parts, so the key stream continues across the writes
+	_, err = enc.Write([]byte(plain[:5]))
+	require.NoError(t, err)
+	_, err = enc.Write([]byte(plain[5:]))
+	require.NoError(t, err)
+
+	require.Equal(t, ciphertext, hex.EncodeToString(out.Bytes()))
+	require.Equal(t, auth, hex.EncodeToString(mac.Sum(nil)[:aesAuthLen]))
+}
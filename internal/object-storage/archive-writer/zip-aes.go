@@ -0,0 +1,231 @@
+package archive_writer
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+// WinZip AES encryption (AE-2), see https://www.winzip.com/en/support/aes-encryption/
+const (
+	methodWinZipAES = 99
+
+	aesExtraID       = 0x9901
+	aesVendorVersion = 2 // AE-2: the CRC is not stored, the authentication code protects the data
+	aesStrength256   = 3
+
+	aesSaltLen    = 16
+	aesKeyLen     = 32
+	aesVerifyLen  = 2
+	aesAuthLen    = 10
+	aesIterations = 1000
+
+	// zipVersionAES is the "version needed to extract" of AES entries
+	zipVersionAES = 51
+
+	flagEncrypted      = 0x1
+	flagDataDescriptor = 0x8
+	flagUTF8           = 0x800
+)
+
+// writeEncrypted writes a deflated entry encrypted with AES-256 in the WinZip format:
+// salt, password verification value, encrypted data and authentication code.
+func (z *zipWriter) writeEncrypted(obj *object_storage.ArchiveObject) error {
+	salt := make([]byte, aesSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return e.Wrap("archive-writer.rand.Read", err)
+	}
+
+	encKey, macKey, verifier, err := aesKeys(z.password, salt)
+	if err != nil {
+		return err
+	}
+
+	header := &zip.FileHeader{
+		Name:           obj.Name,
+		Method:         methodWinZipAES,
+		Flags:          flagEncrypted | flagDataDescriptor,
+		CreatorVersion: zipVersionAES,
+		ReaderVersion:  zipVersionAES,
+		Extra:          aesExtra(zip.Deflate),
+	}
+	if !isASCII(obj.Name) && utf8.ValidString(obj.Name) {
+		header.Flags |= flagUTF8
+	}
+	if !obj.Time.IsZero() {
+		header.ModifiedDate, header.ModifiedTime = msDosTime(obj.Time)
+	}
+
+	raw, err := z.w.CreateRaw(header)
+	if err != nil {
+		return e.Wrap("archive-writer.zip.CreateRaw", err)
+	}
+
+	out := &countWriter{w: raw}
+
+	if _, err := out.Write(salt); err != nil {
+		return e.Wrap("archive-writer.zip.Write", err)
+	}
+	if _, err := out.Write(verifier); err != nil {
+		return e.Wrap("archive-writer.zip.Write", err)
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return e.Wrap("archive-writer.aes.NewCipher", err)
+	}
+
+	mac := hmac.New(sha1.New, macKey)
+
+	enc := &ctrWriter{block: block, w: out, mac: mac}
+
+	compressor, err := flate.NewWriter(enc, flate.DefaultCompression)
+	if err != nil {
+		return e.Wrap("archive-writer.flate.NewWriter", err)
+	}
+
+	size, readErr := io.Copy(compressor, obj.Content)
+
+	// A broken source leaves a truncated, but valid entry
+	if err := compressor.Close(); err != nil {
+		return e.Wrap("archive-writer.flate.Close", err)
+	}
+	if enc.err != nil {
+		return e.Wrap("archive-writer.zip.Write", enc.err)
+	}
+
+	if _, err := out.Write(mac.Sum(nil)[:aesAuthLen]); err != nil {
+		return e.Wrap("archive-writer.zip.Write", err)
+	}
+
+	// Read by the zip writer when the entry is closed
+	header.CompressedSize64 = uint64(out.n)
+	header.UncompressedSize64 = uint64(size)
+	header.CompressedSize = uint32(min(header.CompressedSize64, math.MaxUint32))
+	header.UncompressedSize = uint32(min(header.UncompressedSize64, math.MaxUint32))
+
+	return e.Wrap("archive-writer.io.Copy", readErr)
+}
+
+// aesKeys derives the encryption key, the authentication key and the password verification value.
+func aesKeys(password, salt []byte) (encKey, macKey, verifier []byte, err error) {
+	keys, err := pbkdf2.Key(sha1.New, string(password), salt, aesIterations, 2*aesKeyLen+aesVerifyLen)
+	if err != nil {
+		return nil, nil, nil, e.Wrap("archive-writer.pbkdf2.Key", err)
+	}
+
+	return keys[:aesKeyLen], keys[aesKeyLen : 2*aesKeyLen], keys[2*aesKeyLen:], nil
+}
+
+func aesExtra(method uint16) []byte {
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], aesVendorVersion)
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength256
+	binary.LittleEndian.PutUint16(extra[9:], method)
+
+	return extra
+}
+
+// ctrWriter encrypts with AES in the CTR mode of WinZip:
+// the counter is little-endian and starts at 1, then authenticates the ciphertext.
+type ctrWriter struct {
+	block   cipher.Block
+	w       io.Writer
+	mac     hash.Hash
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	// used bytes of the current key stream block
+	used int
+	buf  []byte
+	err  error
+}
+
+func (c *ctrWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if cap(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	out := c.buf[:len(p)]
+
+	for i := range p {
+		if c.used == 0 || c.used == aes.BlockSize {
+			c.next()
+		}
+		out[i] = p[i] ^ c.stream[c.used]
+		c.used++
+	}
+
+	c.mac.Write(out)
+
+	if _, err := c.w.Write(out); err != nil {
+		c.err = err
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (c *ctrWriter) next() {
+	for i := range c.counter {
+		c.counter[i]++
+		if c.counter[i] != 0 {
+			break
+		}
+	}
+
+	c.block.Encrypt(c.stream[:], c.counter[:])
+	c.used = 0
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// msDosTime converts the time to the MS-DOS date and time of the zip headers.
+func msDosTime(t time.Time) (date uint16, tm uint16) {
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+
+	return date, tm
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
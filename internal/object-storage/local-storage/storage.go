@@ -0,0 +1,173 @@
+package local_storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+// tmpPrefix marks the objects being saved, they are hidden from List.
+const tmpPrefix = ".tmp-"
+
+// Storage keeps every object in a file of the directory.
+type Storage struct {
+	dir string
+}
+
+func New(dir string) (*Storage, error) {
+	if err := os.MkdirAll(dir, 0774); err != nil {
+		return nil, e.Wrap("can't create a local storage dir", err)
+	}
+
+	return &Storage{dir: dir}, nil
+}
+
+// Save writes the content into a temporary file and renames it,
+// so a failed or canceled save leaves no partial object.
+func (s *Storage) Save(ctx context.Context, name string, content io.Reader) (object_storage.ObjectInfo, error) {
+	if err := object_storage.ValidateName(name); err != nil {
+		return object_storage.ObjectInfo{}, err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, tmpPrefix+name+"-*")
+	if err != nil {
+		return object_storage.ObjectInfo{}, e.Wrap("local-storage.os.CreateTemp", err)
+	}
+
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err := io.Copy(tmp, &ctxReader{ctx: ctx, r: content}); err != nil {
+		return object_storage.ObjectInfo{}, e.Wrap("local-storage.io.Copy", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return object_storage.ObjectInfo{}, e.Wrap("local-storage.file.Close", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path(name)); err != nil {
+		return object_storage.ObjectInfo{}, e.Wrap("local-storage.os.Rename", err)
+	}
+
+	ok = true
+
+	return s.Stat(ctx, name)
+}
+
+func (s *Storage) Open(ctx context.Context, name string) (*object_storage.StoredObject, error) {
+	if err := object_storage.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		return nil, wrapNotExist("local-storage.os.Open", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, e.Wrap("local-storage.file.Stat", err)
+	}
+
+	return &object_storage.StoredObject{
+		ObjectInfo: info(name, stat),
+		Content:    file,
+	}, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (object_storage.ObjectInfo, error) {
+	if err := object_storage.ValidateName(name); err != nil {
+		return object_storage.ObjectInfo{}, err
+	}
+
+	stat, err := os.Stat(s.path(name))
+	if err != nil {
+		return object_storage.ObjectInfo{}, wrapNotExist("local-storage.os.Stat", err)
+	}
+
+	return info(name, stat), nil
+}
+
+func (s *Storage) List(ctx context.Context) ([]object_storage.ObjectInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, e.Wrap("local-storage.os.ReadDir", err)
+	}
+
+	out := make([]object_storage.ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		stat, err := entry.Info()
+		if err != nil {
+			// Deleted in the meantime
+			continue
+		}
+
+		out = append(out, info(entry.Name(), stat))
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	if err := object_storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	if err := os.Remove(s.path(name)); err != nil {
+		return wrapNotExist("local-storage.os.Remove", err)
+	}
+
+	return nil
+}
+
+func (s *Storage) path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+func info(name string, stat os.FileInfo) object_storage.ObjectInfo {
+	return object_storage.ObjectInfo{
+		Name:    name,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	}
+}
+
+func wrapNotExist(msg string, err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return object_storage.ErrObjectNotFound
+	}
+
+	return e.Wrap(msg, err)
+}
+
+// ctxReader stops reading once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
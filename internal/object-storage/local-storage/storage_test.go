@@ -0,0 +1,79 @@
+package local_storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	info, err := st.Save(ctx, "b.zip", strings.NewReader("second"))
+	require.NoError(t, err)
+	require.Equal(t, "b.zip", info.Name)
+	require.EqualValues(t, 6, info.Size)
+
+	_, err = st.Save(ctx, "a.zip", strings.NewReader("first"))
+	require.NoError(t, err)
+
+	list, err := st.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "a.zip", list[0].Name)
+	require.Equal(t, "b.zip", list[1].Name)
+
+	obj, err := st.Open(ctx, "b.zip")
+	require.NoError(t, err)
+
+	_, err = obj.Content.Seek(3, io.SeekStart)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(obj.Content)
+	require.NoError(t, err)
+	require.Equal(t, "ond", string(data))
+	require.NoError(t, obj.Content.Close())
+
+	require.NoError(t, st.Delete(ctx, "b.zip"))
+
+	_, err = st.Stat(ctx, "b.zip")
+	require.ErrorIs(t, err, object_storage.ErrObjectNotFound)
+	require.ErrorIs(t, st.Delete(ctx, "b.zip"), object_storage.ErrObjectNotFound)
+
+	_, err = st.Open(ctx, "../b.zip")
+	require.ErrorIs(t, err, object_storage.ErrInvalidName)
+}
+
+func TestStorage_FailedSaveLeavesNoObject(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := t.TempDir()
+
+	st, err := New(dir)
+	require.NoError(t, err)
+
+	_, err = st.Save(ctx, "a.zip", strings.NewReader("data"))
+	require.ErrorIs(t, err, context.Canceled)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	// Temporary files of a save in progress are not listed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, tmpPrefix+"c.zip-1"), []byte("x"), 0664))
+
+	list, err := st.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, list)
+}
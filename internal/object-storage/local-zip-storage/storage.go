@@ -1,61 +1,204 @@
 package local_zip_storage
 
 import (
-	"archive/zip"
-	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
-	"github.com/fandasy/06.08.2025/pkg/e"
+	"context"
+	"io"
+	"net/url"
 	"os"
 	"path"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	archive_writer "github.com/fandasy/06.08.2025/internal/object-storage/archive-writer"
+	local_storage "github.com/fandasy/06.08.2025/internal/object-storage/local-storage"
+	signed_link "github.com/fandasy/06.08.2025/internal/pkg/signed-link"
+	"github.com/fandasy/06.08.2025/pkg/e"
 )
 
+// tempSuffix marks the archives being written, they are renamed to their name on commit.
+// The temp names start with a dot, so they are neither listed nor served.
+const tempSuffix = ".tmp"
+
 type Storage struct {
 	addr string
 	dir  string
+
+	// objects lists the archives of dir
+	objects *local_storage.Storage
+
+	// links signs the download links, nil - links are not signed
+	links *signed_link.Signer
 }
 
-func New(addr string, dir string) (*Storage, error) {
+// New creates the storage, the links to the archives are addr/name.
+// links can be nil, then the links are not signed and never expire.
+func New(addr string, dir string, links *signed_link.Signer) (*Storage, error) {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		if err := os.Mkdir(dir, 0774); err != nil {
 			return nil, e.Wrap("can't create a local zip storage dir", err)
 		}
 	}
 
+	objects, err := local_storage.New(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := removeTempFiles(dir); err != nil {
+		return nil, err
+	}
+
 	return &Storage{
-		addr: addr,
-		dir:  dir,
+		addr:    strings.TrimSuffix(addr, "/"),
+		dir:     dir,
+		objects: objects,
+		links:   links,
 	}, nil
 }
 
-func (s *Storage) SaveArchive(name string, objects []*object_storage.ArchiveObject) (string, error) {
+// removeTempFiles removes the archives left unfinished by the previous run.
+func removeTempFiles(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return e.Wrap("local-zip-storage.os.ReadDir", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, ".") || !strings.HasSuffix(name, tempSuffix) {
+			continue
+		}
+
+		if err := os.Remove(path.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return e.Wrap("local-zip-storage.os.Remove", err)
+		}
+	}
+
+	return nil
+}
+
+// NewArchive creates the archive file, objects are streamed into it one by one.
+// name is the file name, including the extension of the format.
+// The file is written under a temp name and gets its name on commit.
+func (s *Storage) NewArchive(ctx context.Context, name string, opts object_storage.ArchiveOptions) (object_storage.ArchiveWriter, error) {
 	localPath := path.Join(s.dir, name)
 
-	zipFile, err := os.Create(localPath)
+	file, err := os.CreateTemp(s.dir, "."+name+".*"+tempSuffix)
 	if err != nil {
-		return "", e.Wrap("local-zip-storage.os.Create", err)
+		return nil, e.Wrap("local-zip-storage.os.CreateTemp", err)
 	}
-	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	writer, err := archive_writer.New(file, opts)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
 
-	for _, object := range objects {
-		header := &zip.FileHeader{
-			Name:     object.Name,
-			Method:   zip.Deflate,
-			Modified: object.Time,
-		}
+	return &archive{
+		ctx:       ctx,
+		storage:   s,
+		name:      name,
+		localPath: localPath,
+		tempPath:  file.Name(),
+		file:      file,
+		writer:    writer,
+	}, nil
+}
 
-		writer, err := zipWriter.CreateHeader(header)
-		if err != nil {
-			return "", e.Wrap("local-zip-storage.zip.CreateHeader", err)
-		}
+// DeleteArchive removes the archive, a missing archive is not an error.
+func (s *Storage) DeleteArchive(name string) error {
+	err := os.Remove(path.Join(s.dir, path.Base(name)))
+	if err != nil && !os.IsNotExist(err) {
+		return e.Wrap("local-zip-storage.os.Remove", err)
+	}
 
-		if _, err := writer.Write(object.Content); err != nil {
-			return "", e.Wrap("local-zip-storage.writer.Write", err)
-		}
+	return nil
+}
+
+// Link returns the download link of the archive, signed if the storage has a signer.
+// ttl is the lifetime of a signed link, ttl <= 0 - the default lifetime of the signer.
+func (s *Storage) Link(name string, ttl time.Duration) string {
+	name = path.Base(name)
+	link := s.addr + "/" + url.PathEscape(name)
+
+	if s.links == nil {
+		return link
+	}
+
+	if ttl <= 0 {
+		ttl = s.links.TTL()
+	}
+
+	return link + "?" + s.links.Query(name, time.Now().Add(ttl)).Encode()
+}
+
+// ListArchives returns the archives of the storage dir, the ones being written are not listed.
+func (s *Storage) ListArchives(ctx context.Context) ([]object_storage.ObjectInfo, error) {
+	return s.objects.List(ctx)
+}
+
+type archive struct {
+	ctx       context.Context
+	storage   *Storage
+	name      string
+	localPath string
+	tempPath  string
+	file      *os.File
+	writer    archive_writer.Writer
+}
+
+func (a *archive) WriteObject(object *object_storage.ArchiveObject) error {
+	if err := a.ctx.Err(); err != nil {
+		return err
 	}
 
-	url := path.Join(s.addr, name)
+	obj := *object
+	obj.Content = io.NopCloser(&ctxReader{ctx: a.ctx, r: object.Content})
+
+	return a.writer.WriteObject(&obj)
+}
 
-	return url, nil
+func (a *archive) Commit() (string, error) {
+	if err := a.writer.Close(); err != nil {
+		a.Abort()
+		return "", err
+	}
+
+	if err := a.file.Close(); err != nil {
+		os.Remove(a.tempPath)
+		return "", e.Wrap("local-zip-storage.file.Close", err)
+	}
+
+	if err := os.Rename(a.tempPath, a.localPath); err != nil {
+		os.Remove(a.tempPath)
+		return "", e.Wrap("local-zip-storage.os.Rename", err)
+	}
+
+	return a.storage.Link(a.name, 0), nil
+}
+
+func (a *archive) Abort() error {
+	a.file.Close()
+
+	err := os.Remove(a.tempPath)
+	if err != nil && !os.IsNotExist(err) {
+		return e.Wrap("local-zip-storage.os.Remove", err)
+	}
+
+	return nil
+}
+
+// ctxReader stops reading once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
 }
@@ -3,23 +3,25 @@ package local_zip_storage
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"io"
+	"net/url"
 	"os"
 	"path"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 
 	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	signed_link "github.com/fandasy/06.08.2025/internal/pkg/signed-link"
 )
 
 func TestSaveArchive_CreatesZipWithFiles(t *testing.T) {
-	const storageDirName = "TEST"
+	storageDirName := t.TempDir()
 
-	// tmpDir := t.TempDir()
-
-	st, err := New("http://localhost/files", storageDirName)
+	st, err := New("http://localhost/files", storageDirName, nil)
 	require.NoError(t, err)
 
 	pdfPath := "./example-files/file.pdf"
@@ -35,17 +37,24 @@ func TestSaveArchive_CreatesZipWithFiles(t *testing.T) {
 		{
 			Name:    path.Base(pdfPath),
 			Time:    time.Now(),
-			Content: pdfData,
+			Content: io.NopCloser(bytes.NewReader(pdfData)),
 		},
 		{
 			Name:    path.Base(jpgPath),
 			Time:    time.Now(),
-			Content: jpgData,
+			Content: io.NopCloser(bytes.NewReader(jpgData)),
 		},
 	}
 
 	zipName := "test.zip"
-	fullPath, err := st.SaveArchive(zipName, objects)
+	archive, err := st.NewArchive(context.Background(), zipName, object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+
+	for _, obj := range objects {
+		require.NoError(t, archive.WriteObject(obj))
+	}
+
+	fullPath, err := archive.Commit()
 	require.NoError(t, err)
 
 	t.Log(fullPath)
@@ -77,3 +86,141 @@ func TestSaveArchive_CreatesZipWithFiles(t *testing.T) {
 		require.True(t, bytes.Equal(exp, content), "file content mismatch for %s", f.Name)
 	}
 }
+
+func TestSaveArchive_CanceledLeavesNoFile(t *testing.T) {
+	dir := path.Join(t.TempDir(), "zips")
+
+	st, err := New("http://localhost/files", dir, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	archive, err := st.NewArchive(ctx, "canceled.zip", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+
+	require.NoError(t, archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "file.txt",
+		Time:    time.Now(),
+		Content: io.NopCloser(strings.NewReader("data")),
+	}))
+
+	cancel()
+
+	err = archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "file2.txt",
+		Time:    time.Now(),
+		Content: io.NopCloser(strings.NewReader("data")),
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, archive.Abort())
+
+	_, err = os.Stat(path.Join(dir, "canceled.zip"))
+	require.True(t, os.IsNotExist(err), "partial zip must be removed")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "temp file must be removed")
+
+	require.NoError(t, st.DeleteArchive("canceled.zip"))
+}
+
+func TestSaveArchive_HiddenUntilCommit(t *testing.T) {
+	dir := path.Join(t.TempDir(), "zips")
+
+	st, err := New("http://localhost/files", dir, nil)
+	require.NoError(t, err)
+
+	archive, err := st.NewArchive(context.Background(), "partial.zip", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+
+	require.NoError(t, archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "file.txt",
+		Time:    time.Now(),
+		Content: io.NopCloser(strings.NewReader("data")),
+	}))
+
+	_, err = os.Stat(path.Join(dir, "partial.zip"))
+	require.True(t, os.IsNotExist(err), "partial zip must not be visible")
+
+	list, err := st.ListArchives(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, list)
+
+	_, err = archive.Commit()
+	require.NoError(t, err)
+
+	list, err = st.ListArchives(context.Background())
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, "partial.zip", list[0].Name)
+
+	// Temp files left by a crash are removed on start
+	require.NoError(t, os.WriteFile(path.Join(dir, ".crashed.zip.123"+tempSuffix), []byte("x"), 0664))
+
+	_, err = New("http://localhost/files", dir, nil)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestSaveArchive_StreamsLargeObject(t *testing.T) {
+	dir := path.Join(t.TempDir(), "zips")
+
+	st, err := New("http://localhost/files", dir, nil)
+	require.NoError(t, err)
+
+	archive, err := st.NewArchive(context.Background(), "large.zip", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+
+	const size = 64 << 20
+
+	require.NoError(t, archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "zeros.bin",
+		Time:    time.Now(),
+		Content: io.NopCloser(io.LimitReader(zeroReader{}, size)),
+	}))
+
+	_, err = archive.Commit()
+	require.NoError(t, err)
+
+	r, err := zip.OpenReader(path.Join(dir, "large.zip"))
+	require.NoError(t, err)
+	defer r.Close()
+
+	require.Len(t, r.File, 1)
+	require.Equal(t, uint64(size), r.File[0].UncompressedSize64)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestLink(t *testing.T) {
+	dir := path.Join(t.TempDir(), "zips")
+
+	st, err := New("http://localhost:8080/zips/", dir, nil)
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8080/zips/task%20id", st.Link("task id", 0))
+
+	links := signed_link.New("secret", time.Hour)
+
+	st, err = New("http://localhost:8080/zips", dir, links)
+	require.NoError(t, err)
+
+	link, err := url.Parse(st.Link("task-id", 0))
+	require.NoError(t, err)
+	require.Equal(t, "localhost:8080", link.Host)
+	require.Equal(t, "/zips/task-id", link.Path)
+	require.NoError(t, links.Verify("task-id", link.Query(), time.Now()))
+	require.ErrorIs(t, links.Verify("task-id", link.Query(), time.Now().Add(2*time.Hour)), signed_link.ErrLinkExpired)
+
+	link, err = url.Parse(st.Link("task-id", 3*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, links.Verify("task-id", link.Query(), time.Now().Add(2*time.Hour)))
+}
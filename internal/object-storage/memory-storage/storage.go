@@ -0,0 +1,143 @@
+package memory_storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+// Storage keeps objects in memory, it is meant for tests and single-process setups.
+type Storage struct {
+	mu      sync.RWMutex
+	objects map[string]object
+}
+
+type object struct {
+	data    []byte
+	modTime time.Time
+}
+
+func New() *Storage {
+	return &Storage{
+		objects: make(map[string]object),
+	}
+}
+
+func (s *Storage) Save(ctx context.Context, name string, content io.Reader) (object_storage.ObjectInfo, error) {
+	if err := object_storage.ValidateName(name); err != nil {
+		return object_storage.ObjectInfo{}, err
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, &ctxReader{ctx: ctx, r: content}); err != nil {
+		return object_storage.ObjectInfo{}, err
+	}
+
+	obj := object{data: buf.Bytes(), modTime: time.Now()}
+
+	s.mu.Lock()
+	s.objects[name] = obj
+	s.mu.Unlock()
+
+	return obj.info(name), nil
+}
+
+func (s *Storage) Open(ctx context.Context, name string) (*object_storage.StoredObject, error) {
+	obj, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &object_storage.StoredObject{
+		ObjectInfo: obj.info(name),
+		Content:    nopCloser{bytes.NewReader(obj.data)},
+	}, nil
+}
+
+func (s *Storage) Stat(ctx context.Context, name string) (object_storage.ObjectInfo, error) {
+	obj, err := s.get(name)
+	if err != nil {
+		return object_storage.ObjectInfo{}, err
+	}
+
+	return obj.info(name), nil
+}
+
+func (s *Storage) List(ctx context.Context) ([]object_storage.ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]object_storage.ObjectInfo, 0, len(s.objects))
+	for name, obj := range s.objects {
+		out = append(out, obj.info(name))
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, name string) error {
+	if err := object_storage.ValidateName(name); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[name]; !ok {
+		return object_storage.ErrObjectNotFound
+	}
+
+	delete(s.objects, name)
+
+	return nil
+}
+
+func (s *Storage) get(name string) (object, error) {
+	if err := object_storage.ValidateName(name); err != nil {
+		return object{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[name]
+	if !ok {
+		return object{}, object_storage.ErrObjectNotFound
+	}
+
+	return obj, nil
+}
+
+func (o object) info(name string) object_storage.ObjectInfo {
+	return object_storage.ObjectInfo{
+		Name:    name,
+		Size:    int64(len(o.data)),
+		ModTime: o.modTime,
+	}
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error { return nil }
+
+// ctxReader stops reading once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,50 @@
+package memory_storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+
+	st := New()
+
+	_, err := st.Save(ctx, "b.zip", strings.NewReader("second"))
+	require.NoError(t, err)
+
+	_, err = st.Save(ctx, "a.zip", strings.NewReader("first"))
+	require.NoError(t, err)
+
+	info, err := st.Save(ctx, "a.zip", strings.NewReader("replaced"))
+	require.NoError(t, err)
+	require.EqualValues(t, 8, info.Size)
+
+	list, err := st.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, "a.zip", list[0].Name)
+	require.Equal(t, "b.zip", list[1].Name)
+
+	obj, err := st.Open(ctx, "a.zip")
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(obj.Content)
+	require.NoError(t, err)
+	require.Equal(t, "replaced", string(data))
+
+	require.NoError(t, st.Delete(ctx, "a.zip"))
+
+	_, err = st.Stat(ctx, "a.zip")
+	require.ErrorIs(t, err, object_storage.ErrObjectNotFound)
+	require.ErrorIs(t, st.Delete(ctx, "a.zip"), object_storage.ErrObjectNotFound)
+
+	_, err = st.Save(ctx, "dir/a.zip", strings.NewReader("x"))
+	require.ErrorIs(t, err, object_storage.ErrInvalidName)
+}
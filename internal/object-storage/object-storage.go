@@ -1,11 +1,98 @@
 package object_storage
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"time"
 )
 
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrInvalidName    = errors.New("invalid object name")
+)
+
+// ArchiveObject is a file streamed into an archive,
+// Content is read once and closed by the owner of the object.
 type ArchiveObject struct {
+	Name string
+	Time time.Time
+	// Size of the content in bytes, -1 if unknown
+	Size    int64
+	Content io.ReadCloser
+	// Attempts it took to open the object, 0 if unknown
+	Attempts int
+	// URL the content was fetched from after redirects, empty if unknown
+	URL string
+	// ContentType reported by the source, empty if unknown
+	ContentType string
+}
+
+// SourceAuth are the credentials of a protected source.
+type SourceAuth struct {
+	// Headers are sent to the host of the object URL only, not to the hosts it redirects to.
+	// They are secret: never logged, persisted or returned by the API.
+	Headers map[string]string
+	// Credential is the name of a credential defined in the config of the getter,
+	// its secrets stay in the config.
+	Credential string
+}
+
+// ArchiveWriter streams objects into a single archive.
+type ArchiveWriter interface {
+	// WriteObject reads the content of the object until EOF into a new archive entry.
+	WriteObject(obj *ArchiveObject) error
+	// Commit finishes the archive and returns the link to it.
+	Commit() (string, error)
+	// Abort removes the partially written archive.
+	Abort() error
+}
+
+// ObjectStorage keeps named objects in a flat namespace.
+type ObjectStorage interface {
+	// Save reads content until EOF into the object, an existing object is replaced.
+	// The object becomes visible only after the whole content is saved.
+	// Return error:
+	//  - ErrInvalidName
+	Save(ctx context.Context, name string, content io.Reader) (ObjectInfo, error)
+
+	// Open return error:
+	//  - ErrInvalidName
+	//  - ErrObjectNotFound
+	Open(ctx context.Context, name string) (*StoredObject, error)
+
+	// Stat return error:
+	//  - ErrInvalidName
+	//  - ErrObjectNotFound
+	Stat(ctx context.Context, name string) (ObjectInfo, error)
+
+	// List returns all objects sorted by name.
+	List(ctx context.Context) ([]ObjectInfo, error)
+
+	// Delete return error:
+	//  - ErrInvalidName
+	//  - ErrObjectNotFound
+	Delete(ctx context.Context, name string) error
+}
+
+type ObjectInfo struct {
 	Name    string
-	Time    time.Time
-	Content []byte
+	Size    int64
+	ModTime time.Time
+}
+
+// StoredObject is an opened object, Content can seek to serve range requests.
+type StoredObject struct {
+	ObjectInfo
+	Content io.ReadSeekCloser
+}
+
+// ValidateName accepts plain names: no path separators, not empty and not starting with a dot.
+func ValidateName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidName
+	}
+
+	return nil
 }
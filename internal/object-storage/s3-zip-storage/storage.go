@@ -0,0 +1,397 @@
+package s3_zip_storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	archive_writer "github.com/fandasy/06.08.2025/internal/object-storage/archive-writer"
+	"github.com/fandasy/06.08.2025/internal/pkg/sigv4"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+var (
+	ErrInvalidConfig      = errors.New("invalid s3 storage config")
+	ErrUnexpectedResponse = errors.New("unexpected s3 response")
+)
+
+const (
+	// MinPartSize is the S3 minimum size of every part except the last one.
+	MinPartSize = 5 << 20
+	// MaxLinkExpiry is the longest lifetime of a presigned URL allowed by S3.
+	MaxLinkExpiry = 7 * 24 * time.Hour
+
+	defaultPartSize   = 8 << 20
+	defaultLinkExpiry = 24 * time.Hour
+	defaultTimeout    = 5 * time.Minute
+
+	// requestTimeout bounds the requests made outside of the archive context
+	requestTimeout = time.Minute
+)
+
+type Config struct {
+	// Endpoint is the base URL of the S3 API, e.g. https://s3.eu-central-1.amazonaws.com
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// PathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key,
+	// most self-hosted S3-compatible servers need it.
+	PathStyle bool
+
+	// Prefix is prepended to the archive names.
+	Prefix string
+
+	// PartSize is the size of the multipart upload parts, at least MinPartSize.
+	// Every archive being written buffers one part in memory.
+	PartSize int64
+
+	// LinkExpiry is the lifetime of the presigned download links, at most MaxLinkExpiry.
+	LinkExpiry time.Duration
+
+	// Timeout limits every request, the upload of a part included.
+	Timeout time.Duration
+}
+
+// Storage writes archives to an S3-compatible bucket with multipart uploads
+// and returns presigned GET URLs as links.
+type Storage struct {
+	cfg      Config
+	client   *http.Client
+	endpoint *url.URL
+	signer   *sigv4.Signer
+}
+
+func New(client *http.Client, cfg Config) (*Storage, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: endpoint: %s", ErrInvalidConfig, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: endpoint must be an absolute http(s) URL", ErrInvalidConfig)
+	}
+
+	// A stalled endpoint must not hold an archiving worker forever
+	limited := *client
+	limited.Timeout = cfg.Timeout
+
+	return &Storage{
+		cfg:      cfg,
+		client:   &limited,
+		endpoint: endpoint,
+		signer: &sigv4.Signer{
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			Region:    cfg.Region,
+		},
+	}, nil
+}
+
+func (cfg *Config) validate() error {
+	switch {
+	case cfg.Endpoint == "":
+		return fmt.Errorf("%w: endpoint is empty", ErrInvalidConfig)
+	case cfg.Bucket == "":
+		return fmt.Errorf("%w: bucket is empty", ErrInvalidConfig)
+	case cfg.AccessKey == "" || cfg.SecretKey == "":
+		return fmt.Errorf("%w: credentials are empty", ErrInvalidConfig)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PartSize == 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	if cfg.PartSize < MinPartSize {
+		return fmt.Errorf("%w: part size is less than %d bytes", ErrInvalidConfig, MinPartSize)
+	}
+	if cfg.LinkExpiry <= 0 {
+		cfg.LinkExpiry = defaultLinkExpiry
+	}
+	if cfg.LinkExpiry > MaxLinkExpiry {
+		return fmt.Errorf("%w: link expiry is longer than %s", ErrInvalidConfig, MaxLinkExpiry)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	return nil
+}
+
+// NewArchive starts a multipart upload, objects are streamed into it one by one.
+// name is the file name, including the extension of the format.
+func (s *Storage) NewArchive(ctx context.Context, name string, opts object_storage.ArchiveOptions) (object_storage.ArchiveWriter, error) {
+	a := &archive{
+		ctx:     ctx,
+		storage: s,
+		name:    name,
+		key:     s.key(name),
+	}
+
+	// Nothing is written before the first object, so the upload can be started after
+	writer, err := archive_writer.New(a, opts)
+	if err != nil {
+		return nil, err
+	}
+	a.writer = writer
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	query := url.Values{"uploads": {""}}
+	if _, err := s.do(ctx, http.MethodPost, a.key, query, nil, &result); err != nil {
+		return nil, e.Wrap("s3-zip-storage.CreateMultipartUpload", err)
+	}
+
+	a.uploadID = result.UploadID
+
+	return a, nil
+}
+
+// DeleteArchive removes the archive, a missing archive is not an error.
+func (s *Storage) DeleteArchive(name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := s.do(ctx, http.MethodDelete, s.key(name), nil, nil, nil); err != nil {
+		return e.Wrap("s3-zip-storage.DeleteObject", err)
+	}
+
+	return nil
+}
+
+// Link returns a presigned GET URL of the archive.
+// ttl <= 0 - LinkExpiry, a ttl longer than MaxLinkExpiry is cut down to it.
+func (s *Storage) Link(name string, ttl time.Duration) string {
+	if ttl <= 0 {
+		ttl = s.cfg.LinkExpiry
+	}
+	if ttl > MaxLinkExpiry {
+		ttl = MaxLinkExpiry
+	}
+
+	u := s.objectURL(s.key(name))
+
+	filename := path.Base(name)
+	format := object_storage.FormatOf(filename)
+	if format == "" {
+		filename += object_storage.FormatZip.Extension()
+	}
+
+	query := url.Values{}
+	query.Set("response-content-disposition", `attachment; filename="`+filename+`"`)
+	query.Set("response-content-type", format.ContentType())
+	u.RawQuery = query.Encode()
+
+	return s.signer.Presign(u, ttl, time.Now())
+}
+
+func (s *Storage) key(name string) string {
+	return s.cfg.Prefix + path.Base(name)
+}
+
+func (s *Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+
+	return &u
+}
+
+// do sends a signed request, a non-2xx response is returned as an error
+// and an XML response is decoded into out if it is not nil.
+func (s *Storage) do(ctx context.Context, method, key string, query url.Values, body []byte, out any) (http.Header, error) {
+	u := s.objectURL(key)
+	u.RawQuery = sigv4.CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the body hash, so a corrupted part is rejected by the server
+	s.signer.Sign(req, sigv4.HashHex(body), time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, responseError(resp.StatusCode, data)
+	}
+
+	// CompleteMultipartUpload can fail after a 200 response
+	if bytes.Contains(data, []byte("<Error>")) {
+		return nil, responseError(resp.StatusCode, data)
+	}
+
+	if out != nil {
+		if err := xml.Unmarshal(data, out); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnexpectedResponse, err)
+		}
+	}
+
+	return resp.Header, nil
+}
+
+func responseError(statusCode int, body []byte) error {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+
+	if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+		return fmt.Errorf("%w: %d %s: %s", ErrUnexpectedResponse, statusCode, s3Err.Code, s3Err.Message)
+	}
+
+	return fmt.Errorf("%w: %d", ErrUnexpectedResponse, statusCode)
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type archive struct {
+	ctx      context.Context
+	storage  *Storage
+	name     string
+	key      string
+	uploadID string
+
+	writer archive_writer.Writer
+
+	// part is the data not uploaded yet
+	part  bytes.Buffer
+	parts []completedPart
+}
+
+func (a *archive) WriteObject(object *object_storage.ArchiveObject) error {
+	if err := a.ctx.Err(); err != nil {
+		return err
+	}
+
+	obj := *object
+	obj.Content = io.NopCloser(&ctxReader{ctx: a.ctx, r: object.Content})
+
+	return a.writer.WriteObject(&obj)
+}
+
+// Write receives the archive stream and uploads it part by part.
+func (a *archive) Write(p []byte) (int, error) {
+	a.part.Write(p)
+
+	if int64(a.part.Len()) >= a.storage.cfg.PartSize {
+		if err := a.uploadPart(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (a *archive) uploadPart() error {
+	number := len(a.parts) + 1
+
+	query := url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {a.uploadID},
+	}
+
+	header, err := a.storage.do(a.ctx, http.MethodPut, a.key, query, a.part.Bytes(), nil)
+	if err != nil {
+		return e.Wrap("s3-zip-storage.UploadPart", err)
+	}
+
+	a.parts = append(a.parts, completedPart{PartNumber: number, ETag: header.Get("ETag")})
+	a.part.Reset()
+
+	return nil
+}
+
+func (a *archive) Commit() (string, error) {
+	if err := a.writer.Close(); err != nil {
+		a.Abort()
+		return "", err
+	}
+
+	if a.part.Len() > 0 {
+		if err := a.uploadPart(); err != nil {
+			a.Abort()
+			return "", err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: a.parts})
+	if err != nil {
+		a.Abort()
+		return "", e.Wrap("s3-zip-storage.xml.Marshal", err)
+	}
+
+	query := url.Values{"uploadId": {a.uploadID}}
+	if _, err := a.storage.do(a.ctx, http.MethodPost, a.key, query, body, nil); err != nil {
+		a.Abort()
+		return "", e.Wrap("s3-zip-storage.CompleteMultipartUpload", err)
+	}
+
+	return a.storage.Link(a.name, 0), nil
+}
+
+// Abort aborts the multipart upload, the context of the archive may be done already.
+func (a *archive) Abort() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	query := url.Values{"uploadId": {a.uploadID}}
+	if _, err := a.storage.do(ctx, http.MethodDelete, a.key, query, nil, nil); err != nil {
+		return e.Wrap("s3-zip-storage.AbortMultipartUpload", err)
+	}
+
+	return nil
+}
+
+// ctxReader stops reading once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
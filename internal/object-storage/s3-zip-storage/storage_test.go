@@ -0,0 +1,399 @@
+package s3_zip_storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/sigv4"
+)
+
+const (
+	testBucket    = "archives"
+	testAccessKey = "test-access-key"
+	testSecretKey = "test-secret-key"
+)
+
+// fakeS3 is an in-process stand-in for the subset of the S3 API used by the storage,
+// it checks SigV4 signatures of both signed requests and presigned URLs.
+type fakeS3 struct {
+	signer *sigv4.Signer
+
+	mu      sync.Mutex
+	uploads map[string]map[int][]byte
+	objects map[string][]byte
+	nextID  int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		signer:  &sigv4.Signer{AccessKey: testAccessKey, SecretKey: testSecretKey, Region: "us-east-1"},
+		uploads: make(map[string]map[int][]byte),
+		objects: make(map[string][]byte),
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != testBucket || key == "" {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	query := r.URL.Query()
+
+	if r.Method == http.MethodGet && query.Has("X-Amz-Signature") {
+		if !f.checkPresigned(r) {
+			writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+			return
+		}
+	} else {
+		body, err := io.ReadAll(r.Body)
+		if err != nil || !f.checkSigned(r, body) {
+			writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = make(map[int][]byte)
+
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>",
+			bucket, key, id)
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		data, _ := io.ReadAll(r.Body)
+		parts[number] = data
+
+		sum := md5.Sum(data)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+
+		var req struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+
+		var object []byte
+		for i, part := range req.Parts {
+			data, ok := parts[part.PartNumber]
+			sum := md5.Sum(data)
+			if !ok || part.ETag != `"`+hex.EncodeToString(sum[:])+`"` {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			if i < len(req.Parts)-1 && len(data) < MinPartSize {
+				writeS3Error(w, http.StatusBadRequest, "EntityTooSmall")
+				return
+			}
+			object = append(object, data...)
+		}
+
+		f.objects[key] = object
+		delete(f.uploads, query.Get("uploadId"))
+
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+
+		if disposition := query.Get("response-content-disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		w.Write(object)
+
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// checkSigned signs a copy of the request with the signed headers only and compares the result.
+func (f *fakeS3) checkSigned(r *http.Request, body []byte) bool {
+	auth := r.Header.Get("Authorization")
+
+	date, err := time.Parse(sigv4.AmzDateFormat, r.Header.Get(sigv4.HeaderDate))
+	if err != nil {
+		return false
+	}
+
+	payloadHash := r.Header.Get(sigv4.HeaderContentSHA256)
+	if payloadHash != sigv4.HashHex(body) {
+		return false
+	}
+
+	_, signedHeaders, _ := strings.Cut(auth, "SignedHeaders=")
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+
+	u := *r.URL
+	u.Scheme = "http"
+	u.Host = r.Host
+
+	check, _ := http.NewRequest(r.Method, u.String(), nil)
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			check.Header.Set(name, r.Header.Get(name))
+		}
+	}
+
+	f.signer.Sign(check, payloadHash, date)
+
+	return check.Header.Get("Authorization") == auth
+}
+
+// checkPresigned presigns the URL again with the same date and expiry and compares the result.
+func (f *fakeS3) checkPresigned(r *http.Request) bool {
+	query := r.URL.Query()
+
+	date, err := time.Parse(sigv4.AmzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || time.Since(date) > time.Duration(expires)*time.Second {
+		return false
+	}
+
+	unsigned := url.Values{}
+	for k, v := range query {
+		if !strings.HasPrefix(k, "X-Amz-") {
+			unsigned[k] = v
+		}
+	}
+
+	u := *r.URL
+	u.Scheme = "http"
+	u.Host = r.Host
+	u.RawQuery = unsigned.Encode()
+
+	presigned, err := url.Parse(f.signer.Presign(&u, time.Duration(expires)*time.Second, date))
+	if err != nil {
+		return false
+	}
+
+	return presigned.Query().Get("X-Amz-Signature") == query.Get("X-Amz-Signature")
+}
+
+func (f *fakeS3) pendingUploads() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.uploads)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func newTestStorage(t *testing.T) (*Storage, *fakeS3) {
+	t.Helper()
+
+	fake := newFakeS3()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	st, err := New(http.DefaultClient, Config{
+		Endpoint:  server.URL,
+		Bucket:    testBucket,
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+		Prefix:    "zips/",
+		PartSize:  MinPartSize,
+	})
+	require.NoError(t, err)
+
+	return st, fake
+}
+
+func download(t *testing.T, link string) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(link)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, data
+}
+
+func TestS3_MultipartArchive(t *testing.T) {
+	st, fake := newTestStorage(t)
+
+	// Random data doesn't compress, so the archive takes several parts
+	big := make([]byte, 12<<20)
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for i := range big {
+		big[i] = byte(rnd.Uint32())
+	}
+
+	expected := map[string][]byte{
+		"0file.txt": []byte("small file"),
+		"1big.bin":  big,
+	}
+
+	writer, err := st.NewArchive(context.Background(), "task-id", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		require.NoError(t, writer.WriteObject(&object_storage.ArchiveObject{
+			Name:    name,
+			Time:    time.Now(),
+			Content: io.NopCloser(bytes.NewReader(expected[name])),
+		}))
+	}
+
+	link, err := writer.Commit()
+	require.NoError(t, err)
+	require.Contains(t, link, "X-Amz-Signature=")
+	require.Len(t, writer.(*archive).parts, 3)
+	require.Zero(t, fake.pendingUploads())
+
+	resp, data := download(t, link)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, `attachment; filename="task-id.zip"`, resp.Header.Get("Content-Disposition"))
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, r.File, 2)
+
+	for _, f := range r.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+
+		require.True(t, bytes.Equal(expected[f.Name], content), "file content mismatch for %s", f.Name)
+	}
+
+	// A tampered link is rejected
+	resp, _ = download(t, strings.Replace(link, "task-id", "other-id", 1))
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	require.NoError(t, st.DeleteArchive("task-id"))
+
+	resp, _ = download(t, link)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Deleting a missing archive is not an error
+	require.NoError(t, st.DeleteArchive("task-id"))
+}
+
+func TestS3_CanceledArchiveIsAborted(t *testing.T) {
+	st, fake := newTestStorage(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	archive, err := st.NewArchive(ctx, "canceled", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.NoError(t, err)
+	require.Equal(t, 1, fake.pendingUploads())
+
+	require.NoError(t, archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "file.txt",
+		Time:    time.Now(),
+		Content: io.NopCloser(strings.NewReader("data")),
+	}))
+
+	cancel()
+
+	err = archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    "file2.txt",
+		Time:    time.Now(),
+		Content: io.NopCloser(strings.NewReader("data")),
+	})
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.NoError(t, archive.Abort())
+	require.Zero(t, fake.pendingUploads())
+}
+
+func TestS3_ErrorResponse(t *testing.T) {
+	st, _ := newTestStorage(t)
+	st.signer = &sigv4.Signer{AccessKey: testAccessKey, SecretKey: "wrong", Region: "us-east-1"}
+
+	_, err := st.NewArchive(context.Background(), "task-id", object_storage.ArchiveOptions{Format: object_storage.FormatZip})
+	require.ErrorIs(t, err, ErrUnexpectedResponse)
+	require.ErrorContains(t, err, "SignatureDoesNotMatch")
+}
+
+func TestS3_Config(t *testing.T) {
+	_, err := New(http.DefaultClient, Config{Endpoint: "http://localhost:9000", Bucket: "b"})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+
+	_, err = New(http.DefaultClient, Config{
+		Endpoint: "http://localhost:9000", Bucket: "b", AccessKey: "a", SecretKey: "s", PartSize: 1024,
+	})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+
+	st, err := New(http.DefaultClient, Config{
+		Endpoint: "https://s3.eu-central-1.amazonaws.com", Region: "eu-central-1", Bucket: "b", AccessKey: "a", SecretKey: "s",
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(st.Link("id", 0), "https://b.s3.eu-central-1.amazonaws.com/id?"))
+	require.Contains(t, st.Link("id", 0), "X-Amz-Expires=86400")
+	require.Contains(t, st.Link("id", time.Hour), "X-Amz-Expires=3600")
+	require.Contains(t, st.Link("id", 30*24*time.Hour), "X-Amz-Expires=604800")
+}
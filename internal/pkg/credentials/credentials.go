@@ -0,0 +1,86 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+)
+
+var (
+	ErrUnknownCredential = errors.New("unknown credential")
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// Credential is a set of headers defined server-side, the clients refer to it by name.
+type Credential struct {
+	// Domains the headers are sent to, in the format of domain_policy, required:
+	// otherwise a client could send the secret to its own server.
+	Domains []string
+	Headers map[string]string
+}
+
+// Store of the named credentials, a nil Store has none.
+type Store struct {
+	credentials map[string]credential
+}
+
+type credential struct {
+	domains *domain_policy.Policy
+	headers map[string]string
+}
+
+// New returns error:
+//   - ErrInvalidCredential
+func New(creds map[string]Credential) (*Store, error) {
+	s := &Store{credentials: make(map[string]credential, len(creds))}
+
+	for name, c := range creds {
+		if len(c.Domains) == 0 || len(c.Headers) == 0 {
+			return nil, fmt.Errorf("%w: %q needs domains and headers", ErrInvalidCredential, name)
+		}
+
+		domains, err := domain_policy.New(c.Domains, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidCredential, name, err)
+		}
+
+		s.credentials[name] = credential{domains: domains, headers: c.Headers}
+	}
+
+	return s, nil
+}
+
+// Check returns error:
+//   - ErrUnknownCredential
+//   - domain_policy.ErrDomainNotAllowed if the credential is not meant for the host of rawURL
+func (s *Store) Check(name, rawURL string) error {
+	c, ok := s.get(name)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownCredential, name)
+	}
+
+	return c.domains.CheckURL(rawURL)
+}
+
+// Headers returns the headers of the credential if it is meant for the host of u, nil otherwise.
+// Callers must not modify the returned map.
+func (s *Store) Headers(name string, u *url.URL) map[string]string {
+	c, ok := s.get(name)
+	if !ok || c.domains.Check(u.Hostname()) != nil {
+		return nil
+	}
+
+	return c.headers
+}
+
+func (s *Store) get(name string) (credential, bool) {
+	if s == nil {
+		return credential{}, false
+	}
+
+	c, ok := s.credentials[name]
+
+	return c, ok
+}
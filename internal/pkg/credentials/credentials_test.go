@@ -0,0 +1,33 @@
+package credentials
+
+import (
+	"net/url"
+	"testing"
+
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s, err := New(map[string]Credential{
+		"cdn": {Domains: []string{"*.cdn.example"}, Headers: map[string]string{"Authorization": "Bearer secret"}},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Check("cdn", "https://img.cdn.example/a.jpg"))
+	require.ErrorIs(t, s.Check("cdn", "https://evil.example/a.jpg"), domain_policy.ErrDomainNotAllowed)
+	require.ErrorIs(t, s.Check("other", "https://img.cdn.example/a.jpg"), ErrUnknownCredential)
+
+	require.Equal(t, "Bearer secret", s.Headers("cdn", &url.URL{Host: "img.cdn.example"})["Authorization"])
+	require.Nil(t, s.Headers("cdn", &url.URL{Host: "evil.example"}))
+
+	var nilStore *Store
+	require.ErrorIs(t, nilStore.Check("cdn", "https://img.cdn.example/a.jpg"), ErrUnknownCredential)
+
+	// The domains are required, otherwise the secret can be sent anywhere
+	_, err = New(map[string]Credential{"cdn": {Headers: map[string]string{"Authorization": "x"}}})
+	require.ErrorIs(t, err, ErrInvalidCredential)
+
+	_, err = New(map[string]Credential{"cdn": {Domains: []string{"a.*.com"}, Headers: map[string]string{"Authorization": "x"}}})
+	require.ErrorIs(t, err, ErrInvalidCredential)
+}
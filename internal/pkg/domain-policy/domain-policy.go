@@ -0,0 +1,111 @@
+package domain_policy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrDomainNotAllowed = errors.New("domain not allowed")
+	ErrInvalidPattern   = errors.New("invalid domain pattern")
+)
+
+// Policy decides which domains objects can be fetched from.
+// A pattern is a domain, "*.example.com" matches the subdomains of example.com, "*" matches any domain.
+// A nil Policy allows everything.
+type Policy struct {
+	// allowed is empty if any domain that is not blocked is allowed
+	allowed []string
+	blocked []string
+}
+
+// New returns error:
+//   - ErrInvalidPattern
+func New(allowed, blocked []string) (*Policy, error) {
+	p := &Policy{}
+
+	var err error
+
+	p.allowed, err = parsePatterns(allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	p.blocked, err = parsePatterns(blocked)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Check returns ErrDomainNotAllowed if host is blocked or is not in the allowed domains.
+func (p *Policy) Check(host string) error {
+	if p == nil {
+		return nil
+	}
+
+	host = normalize(host)
+
+	if match(p.blocked, host) || (len(p.allowed) > 0 && !match(p.allowed, host)) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, host)
+	}
+
+	return nil
+}
+
+// CheckURL checks the host of rawURL, see Check.
+func (p *Policy) CheckURL(rawURL string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, rawURL)
+	}
+
+	return p.Check(u.Hostname())
+}
+
+func parsePatterns(patterns []string) ([]string, error) {
+	out := make([]string, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+
+		domain := normalize(strings.TrimPrefix(pattern, "*."))
+		if pattern != "*" && (domain == "" || strings.ContainsAny(domain, "*/:@ ")) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPattern, pattern)
+		}
+
+		out = append(out, normalize(pattern))
+	}
+
+	return out, nil
+}
+
+func match(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		switch {
+		case pattern == "*":
+			return true
+
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+
+		case host == pattern:
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
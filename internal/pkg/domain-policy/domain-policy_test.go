@@ -0,0 +1,38 @@
+package domain_policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	p, err := New([]string{"example.com", "*.cdn.example"}, []string{"*.private.cdn.example"})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check("example.com"))
+	require.NoError(t, p.Check("EXAMPLE.com."))
+	require.NoError(t, p.Check("img.cdn.example"))
+	require.NoError(t, p.CheckURL("https://a.b.cdn.example:8443/file.pdf"))
+
+	require.ErrorIs(t, p.Check("cdn.example"), ErrDomainNotAllowed)
+	require.ErrorIs(t, p.Check("www.example.com"), ErrDomainNotAllowed)
+	require.ErrorIs(t, p.Check("x.private.cdn.example"), ErrDomainNotAllowed)
+	require.ErrorIs(t, p.Check("evilcdn.example"), ErrDomainNotAllowed)
+	require.ErrorIs(t, p.CheckURL("https://other.org/file.pdf"), ErrDomainNotAllowed)
+
+	// Without allowed domains everything that is not blocked is allowed
+	p, err = New(nil, []string{"blocked.org"})
+	require.NoError(t, err)
+
+	require.NoError(t, p.Check("other.org"))
+	require.ErrorIs(t, p.Check("blocked.org"), ErrDomainNotAllowed)
+
+	var nilPolicy *Policy
+	require.NoError(t, nilPolicy.CheckURL("https://any.org"))
+
+	for _, pattern := range []string{"", "*.", "a.*.com", "https://example.com", "example.com:80"} {
+		_, err = New([]string{pattern}, nil)
+		require.ErrorIs(t, err, ErrInvalidPattern, pattern)
+	}
+}
@@ -0,0 +1,239 @@
+package http_client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
+)
+
+var (
+	ErrInvalidProxy  = errors.New("invalid proxy url")
+	ErrInvalidCAFile = errors.New("invalid ca file")
+)
+
+// Config of the client that fetches objects.
+// The timeouts are 0 - default, except ResponseHeaderTimeout and Timeout which are 0 - no limit.
+type Config struct {
+	// ConnectTimeout limits the DNS lookup and the TCP connect
+	ConnectTimeout        time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	// Timeout limits the whole request, reading the body included
+	Timeout time.Duration
+
+	// Proxy is an http, https or socks5 proxy url, "" - direct connections
+	Proxy string
+
+	UserAgent string
+	// Headers are added to every request that doesn't set them
+	Headers map[string]string
+
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+
+	// MaxRedirects is 0 - default, -1 - redirects are not followed
+	MaxRedirects int
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DisableKeepAlives   bool
+}
+
+const (
+	defaultConnectTimeout      = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultMaxRedirects        = 10
+	defaultMaxIdleConns        = 100
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+func (cfg *Config) validate() {
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = defaultConnectTimeout
+	}
+	if cfg.TLSHandshakeTimeout <= 0 {
+		cfg.TLSHandshakeTimeout = defaultTLSHandshakeTimeout
+	}
+	if cfg.MaxRedirects == 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+	if cfg.MaxIdleConns <= 0 {
+		cfg.MaxIdleConns = defaultMaxIdleConns
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = defaultIdleConnTimeout
+	}
+}
+
+// New builds the client. With a dialer every connection and redirect is checked by it,
+// nil - any address is reachable.
+//
+// Through a proxy the dialer only sees the proxy address, so the proxy itself is always
+// reachable and the target host is resolved and checked before each request instead.
+// The proxy resolves it again, so this doesn't protect from DNS rebinding.
+//
+// New returns error:
+//   - ErrInvalidProxy
+//   - ErrInvalidCAFile
+func New(cfg Config, dialer *safe_dialer.Dialer) (*http.Client, error) {
+	cfg.validate()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+
+	direct := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = direct.DialContext
+
+	if dialer != nil {
+		dialer = dialer.WithTimeout(cfg.ConnectTimeout)
+		transport.DialContext = dialer.DialContext
+	}
+
+	var proxy *url.URL
+	if cfg.Proxy != "" {
+		var err error
+
+		proxy, err = url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, cfg.Proxy)
+		}
+
+		switch proxy.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, cfg.Proxy)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+		// The proxy is configured by the operator and may be an internal address
+		transport.DialContext = direct.DialContext
+	}
+
+	if cfg.CAFile != "" {
+		pool, err := loadCAFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	var rt http.RoundTripper = transport
+	if proxy != nil && dialer != nil {
+		rt = &proxiedTransport{next: rt, dialer: dialer}
+	}
+	if cfg.UserAgent != "" || len(cfg.Headers) > 0 {
+		rt = &headerTransport{next: rt, userAgent: cfg.UserAgent, headers: cfg.Headers}
+	}
+
+	return &http.Client{
+		Transport:     rt,
+		Timeout:       cfg.Timeout,
+		CheckRedirect: checkRedirect(cfg.MaxRedirects, dialer),
+	}, nil
+}
+
+func checkRedirect(maxRedirects int, dialer *safe_dialer.Dialer) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if maxRedirects < 0 {
+			return http.ErrUseLastResponse
+		}
+
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		if dialer != nil {
+			return dialer.CheckURL(req.URL)
+		}
+
+		return nil
+	}
+}
+
+func loadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCAFile, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%w: no certificates in %s", ErrInvalidCAFile, path)
+	}
+
+	return pool, nil
+}
+
+// headerTransport sets the default headers, the headers of the request win.
+type headerTransport struct {
+	next      http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+
+	for k, v := range t.headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	if t.userAgent != "" && req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+// proxiedTransport checks the target of every request sent through the proxy.
+type proxiedTransport struct {
+	next   http.RoundTripper
+	dialer *safe_dialer.Dialer
+}
+
+func (t *proxiedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.check(req.Context(), req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+func (t *proxiedTransport) check(ctx context.Context, u *url.URL) error {
+	if err := t.dialer.CheckURL(u); err != nil {
+		return err
+	}
+
+	return t.dialer.CheckResolved(ctx, u.Hostname())
+}
@@ -0,0 +1,149 @@
+package http_client
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("User-Agent")+"|"+r.Header.Get("Accept")+"|"+r.Header.Get("X-Team"))
+	}))
+	defer srv.Close()
+
+	client, err := New(Config{
+		UserAgent: "archiver/1.0",
+		Headers:   map[string]string{"Accept": "*/*", "X-Team": "media"},
+	}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, "archiver/1.0|*/*|media", get(t, client, srv.URL, nil))
+
+	// The headers of the request win
+	require.Equal(t, "custom|*/*|media", get(t, client, srv.URL, http.Header{"User-Agent": {"custom"}}))
+}
+
+func TestRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			io.WriteString(w, "done")
+			return
+		}
+
+		http.Redirect(w, r, srv.URL+path.Dir(r.URL.Path), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	client, err := New(Config{MaxRedirects: 3}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, "done", get(t, client, srv.URL+"/a/b", nil))
+
+	_, err = client.Get(srv.URL + "/a/b/c")
+	require.ErrorContains(t, err, "stopped after 3 redirects")
+
+	// -1 returns the redirect itself
+	client, err = New(Config{MaxRedirects: -1}, nil)
+	require.NoError(t, err)
+
+	resp, err := client.Get(srv.URL + "/a")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client, err := New(Config{ResponseHeaderTimeout: 50 * time.Millisecond}, nil)
+	require.NoError(t, err)
+
+	_, err = client.Get(srv.URL)
+	require.ErrorContains(t, err, "timeout awaiting response headers")
+}
+
+func TestCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secure")
+	}))
+	defer srv.Close()
+
+	client, err := New(Config{}, nil)
+	require.NoError(t, err)
+
+	_, err = client.Get(srv.URL)
+	require.Error(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	client, err = New(Config{CAFile: caFile}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, "secure", get(t, client, srv.URL, nil))
+
+	_, err = New(Config{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, nil)
+	require.ErrorIs(t, err, ErrInvalidCAFile)
+}
+
+func TestProxy(t *testing.T) {
+	// A forward proxy receives the absolute url of the target
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxied "+r.URL.String())
+	}))
+	defer proxy.Close()
+
+	_, err := New(Config{Proxy: "ftp://proxy"}, nil)
+	require.ErrorIs(t, err, ErrInvalidProxy)
+
+	// The proxy is on loopback, but it is configured by the operator
+	dialer, err := safe_dialer.New(safe_dialer.Config{})
+	require.NoError(t, err)
+
+	client, err := New(Config{Proxy: proxy.URL}, dialer)
+	require.NoError(t, err)
+
+	require.Equal(t, "proxied http://93.184.216.34/file.pdf", get(t, client, "http://93.184.216.34/file.pdf", nil))
+
+	// The targets are still checked
+	_, err = client.Get("http://127.0.0.1/admin")
+	require.ErrorIs(t, err, safe_dialer.ErrAddressBlocked)
+
+	_, err = client.Get("http://localhost/admin")
+	require.ErrorIs(t, err, safe_dialer.ErrAddressBlocked)
+}
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
@@ -0,0 +1,62 @@
+package sl
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDataURLLog is how much of a data URL is logged
+const maxDataURLLog = 64
+
+// redacted replaces the password and the query values, the same as url.URL.Redacted
+const redacted = "xxxxx"
+
+// urlPattern finds the URLs in a text, e.g. the ones quoted in the errors of net/http
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactURL returns the URL fit for the logs: without the password and the query values,
+// and with a data URL cut to its beginning.
+func RedactURL(link string) string {
+	if len(link) > maxDataURLLog && len(link) > 5 && strings.EqualFold(link[:5], "data:") {
+		return link[:maxDataURLLog] + "...(" + strconv.Itoa(len(link)) + " bytes)"
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+
+	changed := false
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			changed = true
+		}
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for _, values := range query {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+
+		u.RawQuery = query.Encode()
+		changed = true
+	}
+
+	if !changed {
+		return link
+	}
+
+	return u.String()
+}
+
+// RedactURLs redacts every URL found in the text, see RedactURL.
+func RedactURLs(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, RedactURL)
+}
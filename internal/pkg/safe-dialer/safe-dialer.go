@@ -0,0 +1,335 @@
+package safe_dialer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAddressBlocked = errors.New("address is not allowed")
+	ErrInvalidRule    = errors.New("invalid host or cidr rule")
+)
+
+const maxRedirects = 10
+
+// blockedPrefixes are not reachable by default: loopback, private,
+// link-local (cloud metadata), shared, reserved and multicast ranges,
+// and the Teredo and 6to4 ranges that embed an IPv4 address.
+var blockedPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001::/32",
+	"2001:db8::/32",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// Config of the dialer, the rules are host names or CIDRs.
+// A host name matches itself, "*.example.com" matches the subdomains of example.com.
+type Config struct {
+	// Allow are exceptions from the blocked ranges, e.g. an internal file server
+	Allow []string
+	// Deny are blocked in addition to the blocked ranges, they win over Allow
+	Deny []string
+}
+
+// Dialer connects only to the allowed addresses.
+// The host is resolved once and the connection is made to the checked address,
+// so the answer can't change between the check and the dial (DNS rebinding).
+type Dialer struct {
+	dialer   *net.Dialer
+	resolver *net.Resolver
+
+	allowHosts    []string
+	allowPrefixes []netip.Prefix
+	denyHosts     []string
+	denyPrefixes  []netip.Prefix
+}
+
+// New returns error:
+//   - ErrInvalidRule
+func New(cfg Config) (*Dialer, error) {
+	d := &Dialer{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		resolver: net.DefaultResolver,
+	}
+
+	var err error
+
+	d.allowHosts, d.allowPrefixes, err = parseRules(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	d.denyHosts, d.denyPrefixes, err = parseRules(cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Client returns an http client that dials through d and checks every redirect.
+// Proxies are not used, they would hide the real address from the dialer.
+func (d *Dialer) Client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = d.DialContext
+
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: d.CheckRedirect,
+	}
+}
+
+// WithTimeout returns a copy of d with the connect timeout, DNS lookup included.
+func (d *Dialer) WithTimeout(timeout time.Duration) *Dialer {
+	c := *d
+	c.dialer = &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: d.dialer.KeepAlive,
+	}
+
+	return &c
+}
+
+// DialContext resolves the host, checks the addresses and connects to the first allowed one.
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.checkHost(host); err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// The last error: a blocked address or a failed connection
+	var lastErr error
+	for _, addr := range addrs {
+		if err := d.checkAddr(host, addr); err != nil {
+			lastErr = err
+			continue
+		}
+
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// CheckRedirect checks the target of every redirect hop before it is requested,
+// its address is checked again by DialContext.
+func (d *Dialer) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	return d.CheckURL(req.URL)
+}
+
+// CheckURL checks the scheme, the host name and the literal address of u without resolving it.
+func (d *Dialer) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrAddressBlocked, u.Scheme)
+	}
+
+	host := u.Hostname()
+
+	if err := d.checkHost(host); err != nil {
+		return err
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return d.checkAddr(host, addr)
+	}
+
+	return nil
+}
+
+// CheckResolved resolves the host and checks all its addresses.
+// It is meant for requests sent through a proxy, which resolves the host itself:
+// unlike DialContext it can't prevent the answer from changing after the check.
+func (d *Dialer) CheckResolved(ctx context.Context, host string) error {
+	if err := d.checkHost(host); err != nil {
+		return err
+	}
+
+	addrs, err := d.resolve(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if err := d.checkAddr(host, addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkHost checks the host name against the deny list.
+func (d *Dialer) checkHost(host string) error {
+	if matchHost(d.denyHosts, host) {
+		return fmt.Errorf("%w: %s", ErrAddressBlocked, host)
+	}
+
+	return nil
+}
+
+// checkAddr checks the resolved address of the host.
+func (d *Dialer) checkAddr(host string, addr netip.Addr) error {
+	// Contains never matches an address with a zone
+	addr = addr.Unmap().WithZone("")
+
+	if matchPrefix(d.denyPrefixes, addr) {
+		return fmt.Errorf("%w: %s (%s)", ErrAddressBlocked, host, addr)
+	}
+
+	if matchHost(d.allowHosts, host) || matchPrefix(d.allowPrefixes, addr) {
+		return nil
+	}
+
+	if matchPrefix(blockedPrefixes, addr) {
+		return fmt.Errorf("%w: %s (%s)", ErrAddressBlocked, host, addr)
+	}
+
+	return nil
+}
+
+func (d *Dialer) resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+
+	ips, err := d.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	return ips, nil
+}
+
+func parseRules(rules []string) ([]string, []netip.Prefix, error) {
+	var hosts []string
+	var prefixes []netip.Prefix
+
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+
+		if strings.Contains(rule, "/") {
+			prefix, err := netip.ParsePrefix(rule)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %q", ErrInvalidRule, rule)
+			}
+
+			prefixes = append(prefixes, prefix.Masked())
+
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(rule); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		if rule == "" || strings.ContainsAny(rule, ":@ ") {
+			return nil, nil, fmt.Errorf("%w: %q", ErrInvalidRule, rule)
+		}
+
+		hosts = append(hosts, normalizeHost(rule))
+	}
+
+	return hosts, prefixes, nil
+}
+
+func matchHost(rules []string, host string) bool {
+	host = normalizeHost(host)
+
+	for _, rule := range rules {
+		if suffix, ok := strings.CutPrefix(rule, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+
+			continue
+		}
+
+		if host == rule {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchPrefix(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func mustParsePrefixes(prefixes ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		out = append(out, netip.MustParsePrefix(p))
+	}
+
+	return out
+}
@@ -0,0 +1,94 @@
+package safe_dialer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAddr(t *testing.T) {
+	d, err := New(Config{
+		Allow: []string{"10.1.0.0/16", "files.internal", "fd00::1"},
+		Deny:  []string{"8.8.8.0/24", "*.evil.example", "10.1.2.3"},
+	})
+	require.NoError(t, err)
+
+	blocked := []struct{ host, addr string }{
+		{"localhost", "127.0.0.1"},
+		{"metadata", "169.254.169.254"},
+		{"host", "192.168.1.1"},
+		{"host", "::1"},
+		{"host", "::ffff:127.0.0.1"},
+		{"host", "2002:7f00:1::1"},
+		{"host", "2001:0:4136:e378:8000:63bf:3fff:fdd2"},
+		{"host", "fe80::1%eth0"},
+		{"host", "fd00::2"},
+		{"host", "8.8.8.8"},
+		{"host", "10.1.2.3"},
+		{"other.internal", "10.2.0.1"},
+	}
+	for _, tc := range blocked {
+		require.ErrorIs(t, d.checkAddr(tc.host, netip.MustParseAddr(tc.addr)), ErrAddressBlocked, tc.addr)
+	}
+
+	allowed := []struct{ host, addr string }{
+		{"example.com", "93.184.216.34"},
+		{"host", "2606:2800:220:1::1"},
+		{"host", "10.1.0.1"},
+		{"files.internal", "10.2.0.1"},
+		{"FILES.internal.", "10.2.0.1"},
+		{"host", "fd00::1"},
+	}
+	for _, tc := range allowed {
+		require.NoError(t, d.checkAddr(tc.host, netip.MustParseAddr(tc.addr)), tc.addr)
+	}
+
+	require.ErrorIs(t, d.checkHost("a.evil.example"), ErrAddressBlocked)
+	require.NoError(t, d.checkHost("evil.example"))
+
+	_, err = New(Config{Allow: []string{"10.0.0.0/33"}})
+	require.ErrorIs(t, err, ErrInvalidRule)
+
+	_, err = New(Config{Deny: []string{"http://example.com"}})
+	require.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestClient(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "internal")
+	}))
+	defer target.Close()
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(target.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	// Loopback is blocked by default
+	d, err := New(Config{})
+	require.NoError(t, err)
+
+	_, err = d.Client().Get(target.URL)
+	require.ErrorIs(t, err, ErrAddressBlocked)
+
+	d, err = New(Config{Allow: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	resp, err := d.Client().Get(target.URL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, "internal", string(body))
+
+	// Every redirect hop is checked
+	d, err = New(Config{Allow: []string{"127.0.0.0/8"}, Deny: []string{"localhost"}})
+	require.NoError(t, err)
+
+	_, err = d.Client().Get(redirect.URL)
+	require.ErrorIs(t, err, ErrAddressBlocked)
+}
@@ -0,0 +1,32 @@
+package signed_link
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	s := New("secret", 0)
+	require.Equal(t, DefaultTTL, s.TTL())
+
+	now := time.Unix(1700000000, 0)
+	query := s.Query("task-id", now.Add(time.Hour))
+
+	require.NoError(t, s.Verify("task-id", query, now))
+	require.NoError(t, s.Verify("task-id", query, now.Add(time.Hour)))
+	require.ErrorIs(t, s.Verify("task-id", query, now.Add(time.Hour+time.Second)), ErrLinkExpired)
+
+	// The signature covers the name and the expiry time
+	require.ErrorIs(t, s.Verify("other-id", query, now), ErrInvalidSignature)
+
+	extended := url.Values{}
+	extended.Set(ExpiresParam, "9999999999")
+	extended.Set(SignatureParam, query.Get(SignatureParam))
+	require.ErrorIs(t, s.Verify("task-id", extended, now), ErrInvalidSignature)
+
+	require.ErrorIs(t, New("other", 0).Verify("task-id", query, now), ErrInvalidSignature)
+	require.ErrorIs(t, s.Verify("task-id", url.Values{}, now), ErrInvalidSignature)
+}
@@ -3,50 +3,169 @@ package archiver
 import (
 	"context"
 	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	task_store "github.com/fandasy/06.08.2025/internal/task-store"
 	"log/slog"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Archiver interface {
 	// NewTask return error:
 	//  - ErrServiceStopped
 	//  - ErrMaxTasksExceeded
-	NewTask() (string, error)
+	//  - ErrInvalidCallbackURL
+	//  - ErrInvalidFormat
+	//  - ErrPasswordNotSupported
+	//  - ErrInvalidNameConflict
+	NewTask(opts TaskOptions) (string, error)
 
-	// AddObjects return error:
+	// AddObjects returns the added and the replaced objects, the accepted ones are a prefix of objs.
+	// Return error:
 	//  - ErrServiceStopped
 	//  - ErrTaskNotFound
+	//  - ErrInvalidObjectName
+	//  - ErrNameConflict
 	//  - ErrTaskInProgress
 	//  - ErrTaskCompleted
-	AddObjects(id string, urls []string) (int, error)
+	//  - ErrTaskExpired
+	//  - ErrTaskCanceled
+	AddObjects(id string, objs []NewObject) (AddResult, error)
+
+	// SetCallback registers the URL notified when the task is done or failed,
+	// it replaces the previous one, an empty URL removes it.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	//  - ErrInvalidCallbackURL
+	//  - ErrTaskCompleted
+	//  - ErrTaskExpired
+	//  - ErrTaskCanceled
+	SetCallback(id string, callbackURL string) error
+
+	// StartTask starts archiving before the task is filled up to MaxObjects.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	//  - ErrTaskEmpty
+	//  - ErrTaskInProgress
+	//  - ErrTaskCompleted
+	//  - ErrTaskExpired
+	//  - ErrTaskCanceled
+	StartTask(id string) error
+
+	// CancelTask stops a waiting or archiving task and frees its slot.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	//  - ErrTaskCompleted
+	//  - ErrTaskExpired
+	//  - ErrTaskCanceled
+	CancelTask(id string) error
+
+	// DeleteTask cancels the task if needed and removes it together with its archive.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	DeleteTask(id string) error
 
 	// GetStatus return error:
 	//  - ErrServiceStopped
 	//  - ErrTaskNotFound
 	GetStatus(id string) (*TaskInfo, error)
 
+	// NewLink returns a fresh link to the archive of a done task, the task keeps it as its zip link.
+	// ttl is the lifetime of the link, ttl <= 0 - the default lifetime of the saver.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	//  - ErrNoArchive
+	//  - ErrTaskExpired
+	//  - ErrArchiveExpired
+	NewLink(id string, ttl time.Duration) (string, error)
+
+	// Subscribe streams the events of the task. The channel is closed after
+	// the task is done, failed, canceled, expired or deleted, when the service stops
+	// or if the subscriber falls behind. unsubscribe must be called when the events are no longer read.
+	// Return error:
+	//  - ErrServiceStopped
+	//  - ErrTaskNotFound
+	Subscribe(id string) (events <-chan Event, unsubscribe func(), err error)
+
 	Stop(ctx context.Context) error
 }
 
+// AddResult is the outcome of AddObjects, the first Accepted() objects are taken:
+// Added of them take new slots, the rest replace earlier objects with the same name, see NameConflictOverwrite.
+type AddResult struct {
+	Added int
+	// Replaced are the sources of the replaced objects
+	Replaced []string
+}
+
+// Accepted returns the number of the objects taken into the task.
+func (r AddResult) Accepted() int {
+	return r.Added + len(r.Replaced)
+}
+
 type ArchiveObjectGetter interface {
-	ToLink(link string) (*object_storage.ArchiveObject, error)
+	// ToLink opens the object, its Content streams straight from the source.
+	ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error)
 }
 
 type ArchiveSaver interface {
-	SaveArchive(name string, objects []*object_storage.ArchiveObject) (string, error)
+	// NewArchive starts a new archive, writing stops when ctx is done.
+	// name is the file name of the archive, including the extension of the format.
+	NewArchive(ctx context.Context, name string, opts object_storage.ArchiveOptions) (object_storage.ArchiveWriter, error)
+	DeleteArchive(name string) error
+	// Link returns a fresh link to a committed archive, ttl <= 0 - the default lifetime.
+	Link(name string, ttl time.Duration) string
+}
+
+// TaskOptions are set when the task is created.
+type TaskOptions struct {
+	// CallbackURL is notified when the task is done or failed, optional.
+	CallbackURL string
+	// Format of the archive, object_storage.FormatZip if empty.
+	Format object_storage.ArchiveFormat
+	// Password encrypts a zip archive with AES-256, optional.
+	// It is kept only in memory and never logged.
+	Password string
+	// Manifest adds manifest.json and SHA256SUMS to the archive.
+	Manifest bool
+	// NameConflict is the policy for the objects named by the client, NameConflictSuffix if empty.
+	NameConflict NameConflict
+}
+
+type Notifier interface {
+	// Notify sends the final state of the task to callbackURL, it must not block.
+	Notify(id string, callbackURL string, info *TaskInfo)
+}
+
+type TaskStore interface {
+	Load() ([]*task_store.Task, error)
+	Save(t *task_store.Task) error
+	Delete(id string) error
 }
 
 type archiver struct {
 	cfg    Config
 	getter ArchiveObjectGetter
 	saver  ArchiveSaver
+	store  TaskStore
+
+	notifier Notifier
+
+	// downloads limits the number of concurrent downloads across all tasks
+	downloads chan struct{}
 
 	mu    sync.RWMutex
 	tasks map[string]*task
 
 	active atomic.Uint32
 
+	events *broker
+
 	stopOnce sync.Once
 	stopCh   chan struct{}
 	wg       sync.WaitGroup
@@ -57,24 +176,81 @@ type archiver struct {
 type Config struct {
 	MaxTasks   uint32
 	MaxObjects int
+
+	// RequeueInterrupted restarts tasks that were archiving when the service went down,
+	// otherwise they are marked as failed with ErrTaskInterrupted.
+	RequeueInterrupted bool
+
+	// IdleTimeout expires tasks waiting for objects without activity, 0 - never.
+	IdleTimeout time.Duration
+	// Retention expires finished (done or failed) tasks, 0 - never.
+	Retention time.Duration
+	// ExpiredRetention is how long expired tasks are still reported before being removed.
+	ExpiredRetention time.Duration
+	// JanitorInterval is how often the tasks are checked for expiration.
+	JanitorInterval time.Duration
+
+	// DownloadWorkers is the size of the download pool shared by all tasks.
+	DownloadWorkers int
+	// TaskParallelism is the number of objects of one task downloaded at the same time.
+	TaskParallelism int
+
+	// MaxArchiveSize limits the total size of the objects in one archive in bytes, 0 - no limit.
+	MaxArchiveSize int64
+
+	// FixExtension replaces the extension of an entry that doesn't match the content type
+	// reported by the getter, e.g. "photo.png" of a JPEG becomes "photo.jpg".
+	FixExtension bool
+
+	// ArchiveRetention is checked every JanitorInterval if the saver implements ArchiveLister.
+	ArchiveRetention ArchiveRetention
 }
 
-func New(cfg Config, getter ArchiveObjectGetter, saver ArchiveSaver, log *slog.Logger) Archiver {
+// New restores the tasks kept in store, store can be nil, then tasks live only in memory.
+// notifier can be nil, then callback URLs are kept but nobody is notified.
+func New(cfg Config, getter ArchiveObjectGetter, saver ArchiveSaver, store TaskStore, notifier Notifier, log *slog.Logger) (Archiver, error) {
 	cfg.validate()
 
-	return &archiver{
-		cfg:    cfg,
-		getter: getter,
-		saver:  saver,
-		tasks:  make(map[string]*task),
-		stopCh: make(chan struct{}),
-		log:    log,
+	if store == nil {
+		store = nopStore{}
 	}
+
+	if notifier == nil {
+		notifier = nopNotifier{}
+	}
+
+	a := &archiver{
+		cfg:       cfg,
+		getter:    getter,
+		saver:     saver,
+		store:     store,
+		notifier:  notifier,
+		downloads: make(chan struct{}, cfg.DownloadWorkers),
+		tasks:     make(map[string]*task),
+		events:    newBroker(),
+		stopCh:    make(chan struct{}),
+		log:       log,
+	}
+
+	if err := a.restore(); err != nil {
+		return nil, err
+	}
+
+	if cfg.IdleTimeout > 0 || cfg.Retention > 0 || cfg.ArchiveRetention.enabled() {
+		a.wg.Add(1)
+		go a.janitor()
+	}
+
+	return a, nil
 }
 
 const (
-	defaultMaxTasks   = 3
-	defaultMaxObjects = 3
+	defaultMaxTasks         = 3
+	defaultMaxObjects       = 3
+	defaultExpiredRetention = time.Hour
+	defaultJanitorInterval  = time.Minute
+	defaultDownloadWorkers  = 16
+	defaultTaskParallelism  = 4
 )
 
 func (cfg *Config) validate() {
@@ -84,4 +260,19 @@ func (cfg *Config) validate() {
 	if cfg.MaxObjects <= 0 {
 		cfg.MaxObjects = defaultMaxObjects
 	}
+	if cfg.ExpiredRetention <= 0 {
+		cfg.ExpiredRetention = defaultExpiredRetention
+	}
+	if cfg.JanitorInterval <= 0 {
+		cfg.JanitorInterval = defaultJanitorInterval
+	}
+	if cfg.DownloadWorkers <= 0 {
+		cfg.DownloadWorkers = defaultDownloadWorkers
+	}
+	if cfg.TaskParallelism <= 0 {
+		cfg.TaskParallelism = defaultTaskParallelism
+	}
+	if cfg.TaskParallelism > cfg.DownloadWorkers {
+		cfg.TaskParallelism = cfg.DownloadWorkers
+	}
 }
@@ -2,68 +2,131 @@ package archiver
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
 	"log/slog"
+	"net/url"
 	"runtime/debug"
-	"strconv"
 	"sync/atomic"
+	"time"
 
 	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/pkg/e"
 	fast_id "github.com/fandasy/06.08.2025/pkg/fast-id"
 
 	"github.com/google/uuid"
 )
 
 var (
-	ErrMaxTasksExceeded   = errors.New("max tasks exceeded")
-	ErrTaskNotFound       = errors.New("task not found")
-	ErrNoObjectsToArchive = errors.New("no objects to archive")
-	ErrServiceStopped     = errors.New("archiver service stopped")
+	ErrMaxTasksExceeded     = errors.New("max tasks exceeded")
+	ErrTaskNotFound         = errors.New("task not found")
+	ErrNoObjectsToArchive   = errors.New("no objects to archive")
+	ErrServiceStopped       = errors.New("archiver service stopped")
+	ErrArchiveTooLarge      = errors.New("archive size limit exceeded")
+	ErrInvalidCallbackURL   = errors.New("invalid callback url")
+	ErrNoArchive            = errors.New("task has no archive")
+	ErrArchiveExpired       = errors.New("archive expired")
+	ErrInvalidFormat        = errors.New("invalid archive format")
+	ErrPasswordNotSupported = errors.New("password is supported only for zip archives")
 )
 
 // NewTask return error:
 //   - ErrServiceStopped
 //   - ErrMaxTasksExceeded
-func (a *archiver) NewTask() (string, error) {
+//   - ErrInvalidCallbackURL
+//   - ErrInvalidFormat
+//   - ErrPasswordNotSupported
+//   - ErrInvalidNameConflict
+func (a *archiver) NewTask(opts TaskOptions) (string, error) {
 	if a.isStopped() {
 		return "", ErrServiceStopped
 	}
 
+	if err := validateCallbackURL(opts.CallbackURL); err != nil {
+		return "", err
+	}
+
+	format, err := object_storage.ParseArchiveFormat(string(opts.Format))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidFormat, opts.Format)
+	}
+
+	archiveOpts := object_storage.ArchiveOptions{Format: format, Password: opts.Password}
+	if err := archiveOpts.Validate(); err != nil {
+		if errors.Is(err, object_storage.ErrEncryptionNotSupported) {
+			return "", fmt.Errorf("%w: %w", ErrPasswordNotSupported, err)
+		}
+		return "", fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+	}
+
+	nameConflict, err := ParseNameConflict(string(opts.NameConflict))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, opts.NameConflict)
+	}
+
 	if !incrementWithMax(&a.active, a.cfg.MaxTasks) {
 		return "", ErrMaxTasksExceeded
 	}
 
 	id := newID()
 	t := newTask(id, a.cfg.MaxObjects)
+	t.callbackURL = opts.CallbackURL
+	t.format = format
+	t.password = opts.Password
+	t.encrypted = opts.Password != ""
+	t.manifest = opts.Manifest
+	t.nameConflict = nameConflict
 
 	a.mu.Lock()
 	a.tasks[id] = t
 	a.mu.Unlock()
 
+	a.persist(t)
+
 	return id, nil
 }
 
 // AddObjects return error:
 //   - ErrServiceStopped
 //   - ErrTaskNotFound
+//   - ErrInvalidObjectName
+//   - ErrNameConflict
 //   - ErrTaskInProgress
 //   - ErrTaskCompleted
-func (a *archiver) AddObjects(id string, urls []string) (int, error) {
+//   - ErrTaskExpired
+//   - ErrTaskCanceled
+func (a *archiver) AddObjects(id string, objs []NewObject) (AddResult, error) {
 	if a.isStopped() {
-		return 0, ErrServiceStopped
+		return AddResult{}, ErrServiceStopped
+	}
+
+	for _, obj := range objs {
+		if err := ValidateEntry(obj.Path, obj.Name); err != nil {
+			return AddResult{}, err
+		}
 	}
 
 	a.mu.RLock()
 	t, ok := a.tasks[id]
 	a.mu.RUnlock()
 	if !ok {
-		return 0, ErrTaskNotFound
+		return AddResult{}, ErrTaskNotFound
 	}
 
-	toAdd, ready, err := t.AddObjects(urls, a.cfg.MaxObjects)
+	indexes, replaced, ready, err := t.AddObjects(objs, a.cfg.MaxObjects)
 	if err != nil {
-		return 0, err
+		return AddResult{}, err
+	}
+
+	if len(indexes) > 0 {
+		a.persist(t)
+	}
+
+	for i, index := range indexes {
+		a.events.publish(Event{Type: EventObjectAdded, TaskID: id, Object: index, Src: objs[i].URL})
 	}
 
 	if ready {
@@ -71,7 +134,163 @@ func (a *archiver) AddObjects(id string, urls []string) (int, error) {
 		go a.processTask(t)
 	}
 
-	return toAdd, nil
+	return AddResult{Added: len(indexes) - len(replaced), Replaced: replaced}, nil
+}
+
+// SetCallback return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+//   - ErrInvalidCallbackURL
+//   - ErrTaskCompleted
+//   - ErrTaskExpired
+//   - ErrTaskCanceled
+func (a *archiver) SetCallback(id string, callbackURL string) error {
+	if a.isStopped() {
+		return ErrServiceStopped
+	}
+
+	if err := validateCallbackURL(callbackURL); err != nil {
+		return err
+	}
+
+	a.mu.RLock()
+	t, ok := a.tasks[id]
+	a.mu.RUnlock()
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	if err := t.SetCallback(callbackURL); err != nil {
+		return err
+	}
+
+	a.persist(t)
+
+	return nil
+}
+
+// StartTask return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+//   - ErrTaskEmpty
+//   - ErrTaskInProgress
+//   - ErrTaskCompleted
+//   - ErrTaskExpired
+//   - ErrTaskCanceled
+func (a *archiver) StartTask(id string) error {
+	if a.isStopped() {
+		return ErrServiceStopped
+	}
+
+	a.mu.RLock()
+	t, ok := a.tasks[id]
+	a.mu.RUnlock()
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	if err := t.Start(); err != nil {
+		return err
+	}
+
+	a.persist(t)
+
+	a.wg.Add(1)
+	go a.processTask(t)
+
+	return nil
+}
+
+// CancelTask return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+//   - ErrTaskCompleted
+//   - ErrTaskExpired
+//   - ErrTaskCanceled
+func (a *archiver) CancelTask(id string) error {
+	if a.isStopped() {
+		return ErrServiceStopped
+	}
+
+	a.mu.RLock()
+	t, ok := a.tasks[id]
+	a.mu.RUnlock()
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	if _, err := a.cancelTask(t); err != nil {
+		return err
+	}
+
+	a.persist(t)
+
+	a.events.publish(Event{Type: EventTaskCanceled, TaskID: id, Object: -1})
+
+	return nil
+}
+
+// DeleteTask return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+func (a *archiver) DeleteTask(id string) error {
+	if a.isStopped() {
+		return ErrServiceStopped
+	}
+
+	a.mu.Lock()
+	t, ok := a.tasks[id]
+	delete(a.tasks, id)
+	a.mu.Unlock()
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	// Finished tasks have nothing to cancel
+	prev, _ := a.cancelTask(t)
+
+	t.markDeleted()
+	a.events.closeTask(id)
+
+	t.persistMu.Lock()
+	err := a.store.Delete(id)
+	t.persistMu.Unlock()
+	if err != nil {
+		a.log.Error("Failed to delete task from store", slog.String("task id", id), sl.Err(err))
+	}
+
+	// An archiving task removes its partial archive and releases its sources itself when processTask returns,
+	// a canceled one may still be doing it. Only a done task has an archive to delete.
+	switch prev {
+	case StatusArchiving, StatusCanceled:
+		return nil
+
+	case StatusDone:
+		if err := a.saver.DeleteArchive(t.archiveName()); err != nil {
+			a.log.Error("Failed to delete archive", slog.String("archive", t.archiveName()), sl.Err(err))
+		}
+	}
+
+	a.releaseSources(t)
+
+	return nil
+}
+
+// cancelTask cancels the task and returns its previous status, the slot of a waiting task is freed here,
+// the slot of an archiving task is freed when processTask returns.
+func (a *archiver) cancelTask(t *task) (TaskStatus, error) {
+	prev, err := t.Cancel()
+	if err != nil {
+		return prev, err
+	}
+
+	// An archiving task releases its sources when processTask returns
+	if prev == StatusWaitingForObjects {
+		a.active.Add(^uint32(0))
+		a.releaseSources(t)
+	}
+
+	return prev, nil
 }
 
 // GetStatus return error:
@@ -91,6 +310,65 @@ func (a *archiver) GetStatus(id string) (*TaskInfo, error) {
 	return t.Info(), nil
 }
 
+// NewLink return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+//   - ErrNoArchive
+//   - ErrTaskExpired
+//   - ErrArchiveExpired
+func (a *archiver) NewLink(id string, ttl time.Duration) (string, error) {
+	if a.isStopped() {
+		return "", ErrServiceStopped
+	}
+
+	a.mu.RLock()
+	t, ok := a.tasks[id]
+	a.mu.RUnlock()
+	if !ok {
+		return "", ErrTaskNotFound
+	}
+
+	link := a.saver.Link(t.archiveName(), ttl)
+
+	if err := t.relink(link); err != nil {
+		return "", err
+	}
+
+	a.persist(t)
+
+	return link, nil
+}
+
+// Subscribe return error:
+//   - ErrServiceStopped
+//   - ErrTaskNotFound
+func (a *archiver) Subscribe(id string) (<-chan Event, func(), error) {
+	if a.isStopped() {
+		return nil, nil, ErrServiceStopped
+	}
+
+	a.mu.RLock()
+	t, ok := a.tasks[id]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrTaskNotFound
+	}
+
+	s := a.events.subscribe(id)
+	if s == nil {
+		return nil, nil, ErrServiceStopped
+	}
+
+	unsubscribe := func() { a.events.unsubscribe(id, s) }
+
+	// The final event could have been published before the subscription
+	if status, _ := t.lastUpdate(); status.final() || t.isDeleted() {
+		unsubscribe()
+	}
+
+	return s.ch, unsubscribe, nil
+}
+
 func (a *archiver) processTask(t *task) {
 	defer a.active.Add(^uint32(0))
 	defer a.wg.Done()
@@ -100,36 +378,215 @@ func (a *archiver) processTask(t *task) {
 		}
 	}()
 
-	var toSave []*object_storage.ArchiveObject
+	ctx, cancel, ok := t.processing()
+	if !ok {
+		return
+	}
+	defer cancel()
+
+	// A task interrupted by the shutdown keeps its sources for the restart
+	defer func() {
+		if t.finished() {
+			a.releaseSources(t)
+		}
+	}()
+
+	// The archive is created on the first opened object,
+	// objects are streamed into it in the order they were added.
+	var archive object_storage.ArchiveWriter
+	var written int
+	var archiveSize int64
+
+	// entries of the manifest by object index, nil - no manifest
+	var entries map[int]manifestObject
+	// names of the written entries, the names from the URLs may clash with the names set by the client
+	used := make(map[string]struct{})
+	if t.manifest {
+		entries = make(map[int]manifestObject)
+		used[manifestName] = struct{}{}
+		used[checksumName] = struct{}{}
+	}
+
+	objs := t.Objects()
+
+	opened := a.openObjects(ctx, t)
+	defer opened.close()
 
-	for i, obj := range t.Objects() {
-		archObj, err := a.getter.ToLink(obj.src)
+	for i := range opened.results {
+		res, ok := opened.next(ctx, i)
+		if !ok {
+			break
+		}
+		if res.obj == nil {
+			continue
+		}
+
+		if a.cfg.MaxArchiveSize > 0 && res.obj.Size > 0 && archiveSize+res.obj.Size > a.cfg.MaxArchiveSize {
+			res.release()
+
+			t.setObjectError(i, ErrArchiveTooLarge)
+			a.persist(t)
+
+			a.events.publish(Event{Type: EventDownloadFailed, TaskID: t.id, Object: i, Src: res.src, Err: ErrArchiveTooLarge})
+
+			continue
+		}
+
+		if archive == nil {
+			var err error
+			archive, err = a.saver.NewArchive(ctx, t.archiveName(), t.archiveOptions())
+			if err != nil {
+				res.release()
+				a.failTask(ctx, t, nil, e.Wrap("failed to create archive", err))
+				return
+			}
+		}
+
+		src := &sourceReader{r: res.obj.Content, limit: -1}
+		if a.cfg.MaxArchiveSize > 0 {
+			src.limit = a.cfg.MaxArchiveSize - archiveSize
+		}
+		if entries != nil {
+			src.hash = sha256.New()
+		}
+		res.obj.Content = src
+		name := objs[i].entryName(i, res.obj.Name)
+		if a.cfg.FixExtension {
+			name = fixExtension(name, res.obj.ContentType)
+		}
+		res.obj.Name = uniqueName(name, used)
+		used[res.obj.Name] = struct{}{}
+
+		err := archive.WriteObject(res.obj)
+		res.release()
+		archiveSize += src.n
+
+		if entries != nil {
+			entries[i] = manifestObject{
+				Name:        res.obj.Name,
+				Src:         res.src,
+				URL:         res.obj.URL,
+				ContentType: res.obj.ContentType,
+				Size:        src.n,
+				SHA256:      hex.EncodeToString(src.hash.Sum(nil)),
+				FetchedAt:   res.obj.Time,
+			}
+		}
 		if err != nil {
-			a.log.Error("Failed to get archive object", slog.String("object", obj.src), sl.Err(err))
+			if src.err == nil || ctx.Err() != nil {
+				a.failTask(ctx, t, archive, err)
+				return
+			}
+
+			// The source broke in the middle of the transfer, the entry is left truncated
+			a.log.Error("Failed to read archive object", slog.String("object", sl.RedactURL(res.src)), sl.Err(src.err))
 
-			t.setObjectError(i, err)
+			t.setObjectError(i, src.err)
+			a.persist(t)
+
+			a.events.publish(Event{Type: EventDownloadFailed, TaskID: t.id, Object: i, Src: res.src, Size: src.n, Err: src.err})
 
 			continue
 		}
-		archObj.Name = strconv.Itoa(i) + archObj.Name
-		toSave = append(toSave, archObj)
+
+		a.events.publish(Event{Type: EventDownloadFinished, TaskID: t.id, Object: i, Src: res.src, Size: src.n})
+
+		written++
 	}
 
-	if len(toSave) == 0 {
-		t.fail(ErrNoObjectsToArchive)
+	if ctx.Err() != nil {
+		a.failTask(ctx, t, archive, ctx.Err())
 		return
 	}
 
-	link, err := a.saver.SaveArchive(t.id, toSave)
+	if written == 0 {
+		a.failTask(ctx, t, archive, ErrNoObjectsToArchive)
+		return
+	}
+
+	if entries != nil {
+		if err := newManifest(t, entries, time.Now()).write(archive); err != nil {
+			a.failTask(ctx, t, archive, err)
+			return
+		}
+	}
+
+	link, err := archive.Commit()
 	if err != nil {
+		a.failTask(ctx, t, archive, err)
+		return
+	}
+
+	a.events.publish(Event{Type: EventArchiveWritten, TaskID: t.id, Object: -1, Size: archiveSize, Zip: link})
+
+	if !t.complete(link) {
+		// Canceled right after the archive was written
+		if err := a.saver.DeleteArchive(t.archiveName()); err != nil {
+			a.log.Error("Failed to delete archive", slog.String("archive", t.archiveName()), sl.Err(err))
+		}
+
+		return
+	}
+
+	a.persist(t)
+
+	a.events.publish(Event{Type: EventTaskDone, TaskID: t.id, Object: -1, Zip: link})
+
+	a.notify(t)
+}
+
+// failTask aborts the archive and marks the task as failed,
+// a canceled task keeps its status.
+func (a *archiver) failTask(ctx context.Context, t *task, archive object_storage.ArchiveWriter, err error) {
+	if archive != nil {
+		if abortErr := archive.Abort(); abortErr != nil {
+			a.log.Error("Failed to abort archive", slog.String("archive", t.id), sl.Err(abortErr))
+		}
+	}
+
+	if ctx.Err() != nil {
+		a.log.Info("Task canceled", slog.String("task id", t.id))
+		return
+	}
+
+	if !errors.Is(err, ErrNoObjectsToArchive) {
 		a.log.Error("Failed to save archive", slog.String("archive", t.id), sl.Err(err))
+	}
+
+	if t.fail(err) {
+		a.persist(t)
+
+		a.events.publish(Event{Type: EventTaskError, TaskID: t.id, Object: -1, Err: err})
+
+		a.notify(t)
+	}
+}
+
+// taskReleaser is implemented by getters that keep the objects of the tasks, e.g. the uploaded files.
+type taskReleaser interface {
+	ReleaseTask(taskID string) error
+}
+
+// releaseSources lets the getter free the objects of a task that will not be archived again.
+func (a *archiver) releaseSources(t *task) {
+	r, ok := a.getter.(taskReleaser)
+	if !ok {
+		return
+	}
 
-		t.fail(err)
+	if err := r.ReleaseTask(t.id); err != nil {
+		a.log.Error("Failed to release task sources", slog.String("task id", t.id), sl.Err(err))
+	}
+}
 
+// notify sends the final state of the task to its callback URL, if there is one.
+func (a *archiver) notify(t *task) {
+	info := t.Info()
+	if info.CallbackURL == "" {
 		return
 	}
 
-	t.complete(link)
+	a.notifier.Notify(t.id, info.CallbackURL, info)
 }
 
 func (a *archiver) Stop(ctx context.Context) error {
@@ -139,6 +596,9 @@ func (a *archiver) Stop(ctx context.Context) error {
 
 	a.stopOnce.Do(func() { close(a.stopCh) })
 
+	// Event streams would hold up the shutdown of the http server
+	a.events.close()
+
 	done := make(chan struct{})
 	go func() {
 		a.wg.Wait()
@@ -162,10 +622,28 @@ func (a *archiver) isStopped() bool {
 	}
 }
 
+// validateCallbackURL accepts an empty URL or an absolute http(s) URL.
+func validateCallbackURL(callbackURL string) error {
+	if callbackURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCallbackURL, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidCallbackURL, callbackURL)
+	}
+
+	return nil
+}
+
 func incrementWithMax(a *atomic.Uint32, Max uint32) bool {
 	for {
 		current := a.Load()
-		if current == Max {
+		if current >= Max {
 			return false
 		}
 		if a.CompareAndSwap(current, current+1) {
@@ -0,0 +1,235 @@
+package archiver
+
+import (
+	"context"
+	"errors"
+	"hash"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+)
+
+// attemptsError is implemented by getter errors that know
+// how many attempts were made to open the object.
+type attemptsError interface {
+	error
+	Attempts() int
+}
+
+// hostLimiter is implemented by getters that limit the downloads per source host.
+type hostLimiter interface {
+	AcquireHost(ctx context.Context, link string) (release func(), err error)
+}
+
+type openResult struct {
+	src string
+	// obj is nil if the object could not be opened
+	obj *object_storage.ArchiveObject
+	// release closes the object and frees its download slots
+	release func()
+}
+
+type openedObjects struct {
+	results []chan openResult
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// openObjects opens the objects of the task concurrently:
+// at most TaskParallelism per task and DownloadWorkers across all tasks.
+// An opened object holds its slots until it is released, so the number of
+// open source connections stays bounded while they wait to be written in order.
+// The slots of the source host, if the getter limits them, are taken in order as well.
+func (a *archiver) openObjects(ctx context.Context, t *task) *openedObjects {
+	objs := t.Objects()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	o := &openedObjects{
+		results: make([]chan openResult, len(objs)),
+		cancel:  cancel,
+	}
+	for i := range o.results {
+		o.results[i] = make(chan openResult, 1)
+	}
+
+	taskSlots := make(chan struct{}, a.cfg.TaskParallelism)
+
+	hosts, _ := a.getter.(hostLimiter)
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+
+		for i, obj := range objs {
+			select {
+			case taskSlots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case a.downloads <- struct{}{}:
+			case <-ctx.Done():
+				<-taskSlots
+				return
+			}
+
+			releaseHost := func() {}
+			if hosts != nil {
+				release, err := hosts.AcquireHost(ctx, obj.src)
+				if err != nil {
+					<-a.downloads
+					<-taskSlots
+					return
+				}
+				releaseHost = release
+			}
+
+			o.wg.Add(1)
+			go func(i int, src string, auth object_storage.SourceAuth) {
+				defer o.wg.Done()
+
+				var once sync.Once
+				freeSlots := func() {
+					once.Do(func() {
+						releaseHost()
+						<-a.downloads
+						<-taskSlots
+					})
+				}
+
+				res := openResult{src: src, release: freeSlots}
+				defer func() {
+					if r := recover(); r != nil {
+						a.log.Error("Panic recovered", slog.String("stack", string(debug.Stack())))
+						freeSlots()
+					}
+					o.results[i] <- res
+				}()
+
+				a.events.publish(Event{Type: EventDownloadStarted, TaskID: t.id, Object: i, Src: src})
+
+				archObj, err := a.getter.ToLink(ctx, src, auth)
+				if err != nil {
+					freeSlots()
+
+					if ctx.Err() != nil {
+						return
+					}
+
+					attempts := 1
+					var ae attemptsError
+					if errors.As(err, &ae) {
+						attempts = ae.Attempts()
+					}
+
+					a.log.Error("Failed to get archive object",
+						slog.String("object", sl.RedactURL(src)), slog.Int("attempts", attempts), sl.Err(err))
+
+					t.setObjectAttempts(i, attempts)
+					t.setObjectError(i, err)
+					a.persist(t)
+
+					a.events.publish(Event{Type: EventDownloadFailed, TaskID: t.id, Object: i, Src: src, Err: err})
+
+					return
+				}
+
+				attempts := archObj.Attempts
+				if attempts == 0 {
+					attempts = 1
+				}
+				t.setObjectAttempts(i, attempts)
+
+				res.obj = archObj
+				res.release = func() {
+					archObj.Content.Close()
+					freeSlots()
+				}
+			}(i, obj.src, obj.auth)
+		}
+	}()
+
+	return o
+}
+
+// next waits for the i-th object, ok is false if ctx is done first.
+func (o *openedObjects) next(ctx context.Context, i int) (openResult, bool) {
+	select {
+	case res := <-o.results[i]:
+		return res, true
+	case <-ctx.Done():
+		return openResult{}, false
+	}
+}
+
+// close stops opening objects and releases the ones that were not consumed.
+func (o *openedObjects) close() {
+	o.cancel()
+	o.wg.Wait()
+
+	for _, ch := range o.results {
+		select {
+		case res := <-ch:
+			res.release()
+		default:
+		}
+	}
+}
+
+// sourceReader remembers the read error of the object source,
+// to tell it apart from the errors of the archive itself.
+// It also fails with ErrArchiveTooLarge after more than limit bytes, -1 - no limit.
+type sourceReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	err   error
+	// hash of the content read so far, optional
+	hash hash.Hash
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.read(p)
+	if s.hash != nil && n > 0 {
+		s.hash.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (s *sourceReader) read(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	// Read one byte over the limit to tell "exactly at the limit" from "over it"
+	if s.limit >= 0 && int64(len(p)) > s.limit-s.n+1 {
+		p = p[:s.limit-s.n+1]
+	}
+
+	n, err := s.r.Read(p)
+	s.n += int64(n)
+
+	if s.limit >= 0 && s.n > s.limit {
+		over := int(s.n - s.limit)
+		s.n = s.limit
+		s.err = ErrArchiveTooLarge
+		return n - over, s.err
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.err = err
+	}
+	return n, err
+}
+
+func (s *sourceReader) Close() error {
+	return nil
+}
@@ -0,0 +1,190 @@
+package archiver
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	ErrInvalidObjectName   = errors.New("invalid object name")
+	ErrInvalidNameConflict = errors.New("invalid name conflict policy")
+	ErrNameConflict        = errors.New("object name already taken")
+)
+
+// maxNameLen limits one segment of the entry path, the common limit of file systems
+const maxNameLen = 255
+
+// NewObject is an object added to a task.
+type NewObject struct {
+	URL string
+	// Name of the archive entry, optional.
+	// If empty, the name is taken from the final URL and prefixed with the index of the object.
+	Name string
+	// Path is the directory of the entry inside the archive, optional, "/" separated.
+	Path string
+	// Auth is sent with the requests for the object, optional.
+	Auth object_storage.SourceAuth
+}
+
+// NameConflict is the policy for objects added with a name that is already taken in the same directory.
+type NameConflict string
+
+const (
+	// NameConflictSuffix renames the new object to "name (1).ext", "name (2).ext", ...
+	NameConflictSuffix NameConflict = "suffix"
+	// NameConflictOverwrite replaces the earlier object, it doesn't take a new slot.
+	NameConflictOverwrite NameConflict = "overwrite"
+	// NameConflictFail rejects the whole AddObjects call with ErrNameConflict.
+	NameConflictFail NameConflict = "fail"
+)
+
+// ParseNameConflict returns NameConflictSuffix for an empty policy.
+func ParseNameConflict(s string) (NameConflict, error) {
+	switch p := NameConflict(s); p {
+	case "":
+		return NameConflictSuffix, nil
+	case NameConflictSuffix, NameConflictOverwrite, NameConflictFail:
+		return p, nil
+	}
+
+	return "", ErrInvalidNameConflict
+}
+
+// ValidateEntry checks the name and the directory of an object, so that
+// the entry can't escape the extraction directory (zip slip).
+// Both are optional, the directory is "/" separated and may end with "/".
+func ValidateEntry(dir, name string) error {
+	if name != "" {
+		if err := validateSegment(name); err != nil {
+			return err
+		}
+	}
+
+	if dir == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(dir, "/") {
+		return fmt.Errorf("%w: absolute path %q", ErrInvalidObjectName, dir)
+	}
+
+	for _, segment := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+		if err := validateSegment(segment); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateSegment(s string) error {
+	switch {
+	case s == "" || s == "." || s == "..":
+		return fmt.Errorf("%w: %q", ErrInvalidObjectName, s)
+	case len(s) > maxNameLen:
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidObjectName, maxNameLen)
+	case !utf8.ValidString(s):
+		return fmt.Errorf("%w: not valid UTF-8", ErrInvalidObjectName)
+	}
+
+	for _, r := range s {
+		// Backslash is a separator on Windows, colon starts a drive or an alternate data stream
+		if r == '/' || r == '\\' || r == ':' || r < 0x20 || r == 0x7f {
+			return fmt.Errorf("%w: %q", ErrInvalidObjectName, s)
+		}
+	}
+
+	return nil
+}
+
+// entryName is the name of the object inside the archive,
+// base is the file name of the source.
+func (o object) entryName(index int, base string) string {
+	name := o.name
+	if name == "" {
+		name = strconv.Itoa(index) + base
+	}
+
+	return path.Join(o.path, name)
+}
+
+// key identifies an object named by the client in its directory.
+func (o object) key() string {
+	return path.Join(o.path, o.name)
+}
+
+// fixExtension replaces the extension of the entry with the one of the content type,
+// if the entry has no extension or its extension is of another type.
+// The extension of a subtype is kept (".csv" for text/plain), and so is an extension
+// unknown to the detector for plain text (".md"), the content can't tell them apart.
+func fixExtension(name, contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return name
+	}
+
+	// Unknown types, application/octet-stream among them, have no extension
+	detected := mimetype.Lookup(mediaType)
+	if detected == nil || detected.Extension() == "" {
+		return name
+	}
+
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = ""
+	}
+
+	if ext != "" {
+		if strings.EqualFold(ext, detected.Extension()) {
+			return name
+		}
+
+		byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(ext)))
+		if err == nil {
+			m := mimetype.Lookup(byExt)
+			if m == nil && detected.Is("text/plain") && strings.HasPrefix(byExt, "text/") {
+				return name
+			}
+
+			for ; m != nil; m = m.Parent() {
+				if m.Is(mediaType) {
+					return name
+				}
+			}
+		}
+	}
+
+	return strings.TrimSuffix(name, ext) + detected.Extension()
+}
+
+// withSuffix returns "name (n).ext".
+func withSuffix(name string, n int) string {
+	ext := path.Ext(name)
+	if ext == path.Base(name) {
+		ext = ""
+	}
+
+	return strings.TrimSuffix(name, ext) + " (" + strconv.Itoa(n) + ")" + ext
+}
+
+// uniqueName returns name, or the first name with a suffix that is not in used.
+func uniqueName(name string, used map[string]struct{}) string {
+	if _, ok := used[name]; !ok {
+		return name
+	}
+
+	for n := 1; ; n++ {
+		candidate := withSuffix(name, n)
+		if _, ok := used[candidate]; !ok {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package archiver
+
+import (
+	"sync"
+)
+
+type EventType string
+
+const (
+	EventObjectAdded      EventType = "object_added"
+	EventDownloadStarted  EventType = "download_started"
+	EventDownloadFinished EventType = "download_finished"
+	EventDownloadFailed   EventType = "download_failed"
+	EventArchiveWritten   EventType = "archive_written"
+	EventTaskDone         EventType = "task_done"
+	EventTaskError        EventType = "task_error"
+	EventTaskCanceled     EventType = "task_canceled"
+	EventTaskExpired      EventType = "task_expired"
+)
+
+// final events end the event stream of the task.
+func (t EventType) final() bool {
+	switch t {
+	case EventTaskDone, EventTaskError, EventTaskCanceled, EventTaskExpired:
+		return true
+	}
+
+	return false
+}
+
+// Event describes a change of the task, fields not related to the Type are empty.
+type Event struct {
+	Type   EventType
+	TaskID string
+	// Object is the index of the object in the task, -1 for the events of the whole task
+	Object int
+	Src    string
+	// Size is the number of bytes written to the archive
+	Size int64
+	Zip  string
+	Err  error
+}
+
+// subscriberBuffer is the number of events a subscriber may lag behind,
+// a slower subscriber is disconnected so it can't stall the archiving.
+const subscriberBuffer = 64
+
+type subscriber struct {
+	ch chan Event
+}
+
+// broker fans out the events of a task to its subscribers.
+type broker struct {
+	mu     sync.Mutex
+	subs   map[string]map[*subscriber]struct{}
+	closed bool
+}
+
+func newBroker() *broker {
+	return &broker{
+		subs: make(map[string]map[*subscriber]struct{}),
+	}
+}
+
+// subscribe returns nil if the broker is closed.
+func (b *broker) subscribe(id string) *subscriber {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	s := &subscriber{ch: make(chan Event, subscriberBuffer)}
+
+	if b.subs[id] == nil {
+		b.subs[id] = make(map[*subscriber]struct{})
+	}
+	b.subs[id][s] = struct{}{}
+
+	return s
+}
+
+// unsubscribe closes the channel of the subscriber if it is still open.
+func (b *broker) unsubscribe(id string, s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(id, s)
+}
+
+func (b *broker) publish(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs[ev.TaskID] {
+		select {
+		case s.ch <- ev:
+		default:
+			b.remove(ev.TaskID, s)
+		}
+	}
+
+	if ev.Type.final() {
+		b.closeTaskLocked(ev.TaskID)
+	}
+}
+
+// closeTask ends the event streams of the task.
+func (b *broker) closeTask(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closeTaskLocked(id)
+}
+
+// close ends all event streams, later subscriptions are refused.
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for id := range b.subs {
+		b.closeTaskLocked(id)
+	}
+	b.closed = true
+}
+
+func (b *broker) closeTaskLocked(id string) {
+	for s := range b.subs[id] {
+		close(s.ch)
+	}
+	delete(b.subs, id)
+}
+
+func (b *broker) remove(id string, s *subscriber) {
+	subs, ok := b.subs[id]
+	if !ok {
+		return
+	}
+
+	if _, ok := subs[s]; !ok {
+		return
+	}
+
+	close(s.ch)
+	delete(subs, s)
+
+	if len(subs) == 0 {
+		delete(b.subs, id)
+	}
+}
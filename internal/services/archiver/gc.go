@@ -0,0 +1,104 @@
+package archiver
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+)
+
+// ArchiveLister is implemented by the savers whose archives can be collected by the archive GC.
+type ArchiveLister interface {
+	// ListArchives returns all archives of the saver,
+	// the archive name is the task id with the extension of the format.
+	ListArchives(ctx context.Context) ([]object_storage.ObjectInfo, error)
+}
+
+// ArchiveRetention limits the archives kept by the saver, a zero field is not limited.
+type ArchiveRetention struct {
+	// MaxAge of an archive since it was written.
+	MaxAge time.Duration
+	// MaxTotalSize of all archives in bytes.
+	MaxTotalSize int64
+	// MaxCount of archives.
+	MaxCount int
+}
+
+func (r ArchiveRetention) enabled() bool {
+	return r.MaxAge > 0 || r.MaxTotalSize > 0 || r.MaxCount > 0
+}
+
+// collectArchives deletes archives, oldest first, while they are older than MaxAge
+// or there are more of them than MaxTotalSize and MaxCount allow.
+// Archives of the tasks still archiving are kept but count towards the limits, if the storage lists them.
+// The tasks of the deleted archives move to StatusArchiveExpired.
+func (a *archiver) collectArchives(now time.Time) {
+	lister, ok := a.saver.(ArchiveLister)
+	if !ok || !a.cfg.ArchiveRetention.enabled() {
+		return
+	}
+
+	archives, err := lister.ListArchives(context.Background())
+	if err != nil {
+		a.log.Error("Failed to list archives", sl.Err(err))
+		return
+	}
+
+	sort.Slice(archives, func(i, j int) bool { return archives[i].ModTime.Before(archives[j].ModTime) })
+
+	var totalSize int64
+	for _, arch := range archives {
+		totalSize += arch.Size
+	}
+	count := len(archives)
+
+	limits := a.cfg.ArchiveRetention
+
+	var deleted int
+	var freed int64
+
+	for _, arch := range archives {
+		overAge := limits.MaxAge > 0 && now.Sub(arch.ModTime) > limits.MaxAge
+		overSize := limits.MaxTotalSize > 0 && totalSize > limits.MaxTotalSize
+		overCount := limits.MaxCount > 0 && count > limits.MaxCount
+
+		if !overAge && !overSize && !overCount {
+			// The rest are newer and the totals already fit
+			break
+		}
+
+		id := strings.TrimSuffix(arch.Name, object_storage.FormatOf(arch.Name).Extension())
+
+		a.mu.RLock()
+		t, ok := a.tasks[id]
+		a.mu.RUnlock()
+
+		if ok {
+			if status, _ := t.lastUpdate(); status == StatusArchiving {
+				continue
+			}
+		}
+
+		if err := a.saver.DeleteArchive(arch.Name); err != nil {
+			a.log.Error("Failed to delete archive", slog.String("archive", arch.Name), sl.Err(err))
+			continue
+		}
+
+		totalSize -= arch.Size
+		count--
+		deleted++
+		freed += arch.Size
+
+		if ok && t.expireArchive() {
+			a.persist(t)
+		}
+	}
+
+	if deleted > 0 {
+		a.log.Info("Archives collected", slog.Int("deleted", deleted), slog.Int64("freed", freed))
+	}
+}
@@ -0,0 +1,97 @@
+package archiver
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+)
+
+// janitor periodically expires idle and finished tasks
+// and collects old archives until the service stops.
+func (a *archiver) janitor() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.cfg.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopCh:
+			return
+		case now := <-ticker.C:
+			a.evict(now)
+			a.collectArchives(now)
+		}
+	}
+}
+
+// evict:
+//   - expires tasks waiting for objects longer than IdleTimeout and frees their slot
+//   - expires finished, canceled and archive expired tasks older than Retention, deletes the archives of the done ones
+//   - removes expired tasks older than ExpiredRetention
+func (a *archiver) evict(now time.Time) {
+	a.mu.RLock()
+	tasks := make([]*task, 0, len(a.tasks))
+	for _, t := range a.tasks {
+		tasks = append(tasks, t)
+	}
+	a.mu.RUnlock()
+
+	var expired, removed int
+
+	for _, t := range tasks {
+		if a.cfg.IdleTimeout > 0 {
+			if _, ok := t.expire(now.Add(-a.cfg.IdleTimeout), StatusWaitingForObjects); ok {
+				a.active.Add(^uint32(0))
+				a.persist(t)
+				a.releaseSources(t)
+				a.events.publish(Event{Type: EventTaskExpired, TaskID: t.id, Object: -1})
+				expired++
+
+				continue
+			}
+		}
+
+		if a.cfg.Retention > 0 {
+			if prev, ok := t.expire(now.Add(-a.cfg.Retention), StatusDone, StatusError, StatusCanceled, StatusArchiveExpired); ok {
+				a.persist(t)
+
+				// The archive of a done task is no longer reachable
+				if prev == StatusDone {
+					if err := a.saver.DeleteArchive(t.archiveName()); err != nil {
+						a.log.Error("Failed to delete archive", slog.String("archive", t.archiveName()), sl.Err(err))
+					}
+				}
+
+				a.events.publish(Event{Type: EventTaskExpired, TaskID: t.id, Object: -1})
+				expired++
+
+				continue
+			}
+		}
+
+		status, updatedAt := t.lastUpdate()
+		if status == StatusExpired && now.Sub(updatedAt) > a.cfg.ExpiredRetention {
+			a.mu.Lock()
+			delete(a.tasks, t.id)
+			a.mu.Unlock()
+
+			// A persist running concurrently must not bring the task back to the store
+			t.markDeleted()
+
+			t.persistMu.Lock()
+			err := a.store.Delete(t.id)
+			t.persistMu.Unlock()
+			if err != nil {
+				a.log.Error("Failed to delete task from store", slog.String("task id", t.id), sl.Err(err))
+			}
+
+			removed++
+		}
+	}
+
+	if expired > 0 || removed > 0 {
+		a.log.Info("Tasks evicted", slog.Int("expired", expired), slog.Int("removed", removed))
+	}
+}
@@ -0,0 +1,112 @@
+package archiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+const (
+	manifestName = "manifest.json"
+	checksumName = "SHA256SUMS"
+)
+
+// manifest describes the content of the archive, see TaskOptions.Manifest.
+// The names of the metadata files are taken first, an object named the same by the client gets a suffix.
+// The archive can be downloaded by anyone with the link, so the URLs and the errors are redacted.
+type manifest struct {
+	TaskID    string           `json:"task_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Objects   []manifestObject `json:"objects"`
+	Failed    []manifestFailed `json:"failed"`
+}
+
+type manifestObject struct {
+	// Name of the archive entry
+	Name        string    `json:"name"`
+	Src         string    `json:"src"`
+	URL         string    `json:"url,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+type manifestFailed struct {
+	Src string `json:"src"`
+	// Name of the truncated archive entry, empty if the object was not written
+	Name string `json:"name,omitempty"`
+	Err  string `json:"error"`
+}
+
+// newManifest collects the written objects and the failed ones from the task.
+// written holds the entries of the archive by object index.
+func newManifest(t *task, written map[int]manifestObject, now time.Time) *manifest {
+	m := &manifest{
+		TaskID:    t.id,
+		CreatedAt: now,
+		Objects:   make([]manifestObject, 0, len(written)),
+		Failed:    make([]manifestFailed, 0),
+	}
+
+	for i, o := range t.Objects() {
+		entry, ok := written[i]
+
+		if o.err != nil {
+			m.Failed = append(m.Failed, manifestFailed{
+				Src:  sl.RedactURL(o.src),
+				Name: entry.Name,
+				Err:  sl.RedactURLs(o.err.Error()),
+			})
+			continue
+		}
+
+		if ok {
+			entry.Src = sl.RedactURL(entry.Src)
+			entry.URL = sl.RedactURL(entry.URL)
+			m.Objects = append(m.Objects, entry)
+		}
+	}
+
+	return m
+}
+
+// write adds manifest.json and SHA256SUMS to the archive.
+func (m *manifest) write(archive object_storage.ArchiveWriter) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return e.Wrap("failed to encode manifest", err)
+	}
+
+	if err := writeFile(archive, manifestName, data, m.CreatedAt); err != nil {
+		return err
+	}
+
+	// The format of sha256sum, verified with "sha256sum -c SHA256SUMS"
+	var sums bytes.Buffer
+	for _, o := range m.Objects {
+		fmt.Fprintf(&sums, "%s  %s\n", o.SHA256, o.Name)
+	}
+
+	return writeFile(archive, checksumName, sums.Bytes(), m.CreatedAt)
+}
+
+func writeFile(archive object_storage.ArchiveWriter, name string, data []byte, modTime time.Time) error {
+	err := archive.WriteObject(&object_storage.ArchiveObject{
+		Name:    name,
+		Time:    modTime,
+		Size:    int64(len(data)),
+		Content: io.NopCloser(bytes.NewReader(data)),
+	})
+	if err != nil {
+		return e.Wrap("failed to write "+name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,265 @@
+package archiver
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	"github.com/fandasy/06.08.2025/internal/pkg/logger/sl"
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
+	"github.com/fandasy/06.08.2025/internal/services/archiver/utils"
+	task_store "github.com/fandasy/06.08.2025/internal/task-store"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+var (
+	ErrTaskInterrupted = errors.New("task interrupted by service restart")
+	ErrPasswordLost    = errors.New("archive password lost on service restart")
+	ErrHeadersLost     = errors.New("request headers lost on service restart")
+)
+
+// restore loads tasks from the store:
+//   - tasks waiting for objects keep waiting and occupy a slot
+//   - interrupted tasks are requeued or marked as failed, see Config.RequeueInterrupted
+//   - unfinished encrypted tasks fail with ErrPasswordLost, the password is not persisted
+//   - unfinished tasks with request headers fail with ErrHeadersLost, the headers are not persisted either
+//   - the tasks failed here release their sources, e.g. the uploaded files
+func (a *archiver) restore() error {
+	records, err := a.store.Load()
+	if err != nil {
+		return e.Wrap("failed to load tasks", err)
+	}
+
+	var toProcess, toRelease []*task
+
+	for _, rec := range records {
+		t := taskFromRecord(rec)
+
+		if t.encrypted && (t.status == StatusWaitingForObjects || t.status == StatusArchiving) {
+			t.status = StatusError
+			t.err = ErrPasswordLost
+			t.updatedAt = time.Now()
+			a.persist(t)
+			toRelease = append(toRelease, t)
+		}
+
+		if t.hasHeaders() && (t.status == StatusWaitingForObjects || t.status == StatusArchiving) {
+			t.status = StatusError
+			t.err = ErrHeadersLost
+			t.updatedAt = time.Now()
+			a.persist(t)
+			toRelease = append(toRelease, t)
+		}
+
+		switch t.status {
+		case StatusWaitingForObjects:
+			a.active.Add(1)
+
+		case StatusArchiving:
+			if a.cfg.RequeueInterrupted {
+				a.active.Add(1)
+				t.resetObjectErrors()
+				toProcess = append(toProcess, t)
+			} else {
+				t.fail(ErrTaskInterrupted)
+				a.persist(t)
+				toRelease = append(toRelease, t)
+			}
+		}
+
+		a.tasks[t.id] = t
+	}
+
+	if len(records) > 0 {
+		a.log.Info("Tasks restored", slog.Int("tasks", len(records)), slog.Int("requeued", len(toProcess)))
+	}
+
+	for _, t := range toRelease {
+		a.releaseSources(t)
+	}
+
+	for _, t := range toProcess {
+		a.wg.Add(1)
+		go a.processTask(t)
+	}
+
+	return nil
+}
+
+// persist writes the current state of the task to the store.
+// Store errors are only logged, the in-memory state stays authoritative.
+func (a *archiver) persist(t *task) {
+	t.persistMu.Lock()
+	defer t.persistMu.Unlock()
+
+	if t.isDeleted() {
+		return
+	}
+
+	if err := a.store.Save(t.record()); err != nil {
+		a.log.Error("Failed to persist task", slog.String("task id", t.id), sl.Err(err))
+	}
+}
+
+func (t *task) record() *task_store.Task {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	objs := make([]task_store.Object, 0, len(t.objects))
+	for _, o := range t.objects {
+		objs = append(objs, task_store.Object{
+			Src:        o.src,
+			Name:       o.name,
+			Path:       o.path,
+			Credential: o.auth.Credential,
+			Headers:    o.headers,
+			Err:        errString(o.err),
+			ErrCode:    errCode(o.err),
+			Attempts:   o.attempts,
+		})
+	}
+
+	return &task_store.Task{
+		ID:           t.id,
+		Status:       int8(t.status),
+		Objects:      objs,
+		Zip:          t.zip,
+		Err:          errString(t.err),
+		ErrCode:      errCode(t.err),
+		CallbackURL:  t.callbackURL,
+		Format:       string(t.format),
+		Encrypted:    t.encrypted,
+		Manifest:     t.manifest,
+		NameConflict: string(t.nameConflict),
+		UpdatedAt:    t.updatedAt,
+	}
+}
+
+func taskFromRecord(rec *task_store.Task) *task {
+	objs := make([]object, 0, len(rec.Objects))
+	for _, o := range rec.Objects {
+		objs = append(objs, object{
+			src:      o.Src,
+			name:     o.Name,
+			path:     o.Path,
+			auth:     object_storage.SourceAuth{Credential: o.Credential},
+			headers:  o.Headers,
+			err:      storedErr(o.Err, o.ErrCode),
+			attempts: o.Attempts,
+		})
+	}
+
+	updatedAt := rec.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	return &task{
+		id:           rec.ID,
+		status:       TaskStatus(rec.Status),
+		objects:      objs,
+		zip:          rec.Zip,
+		err:          storedErr(rec.Err, rec.ErrCode),
+		callbackURL:  rec.CallbackURL,
+		format:       object_storage.ArchiveFormat(rec.Format),
+		encrypted:    rec.Encrypted,
+		manifest:     rec.Manifest,
+		nameConflict: NameConflict(rec.NameConflict),
+		updatedAt:    updatedAt,
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+// errCodes are the stable codes of the sentinel errors that are persisted with the tasks.
+// The codes are written to the store, don't change them.
+var errCodes = []struct {
+	code string
+	err  error
+}{
+	{"task_interrupted", ErrTaskInterrupted},
+	{"password_lost", ErrPasswordLost},
+	{"headers_lost", ErrHeadersLost},
+	{"no_objects_to_archive", ErrNoObjectsToArchive},
+	{"archive_too_large", ErrArchiveTooLarge},
+	{"task_canceled", ErrTaskCanceled},
+	{"task_expired", ErrTaskExpired},
+	{"file_not_found", utils.ErrFileNotFound},
+	{"incorrect_format", utils.ErrIncorrectFormat},
+	{"bad_request", utils.ErrBadRequest},
+	{"authentication_required", utils.ErrAuthenticationRequired},
+	{"access_denied", utils.ErrAccessDenied},
+	{"internal_source_error", utils.ErrInternalSourceError},
+	{"file_too_large", utils.ErrFileTooLarge},
+	{"unsupported_scheme", utils.ErrUnsupportedScheme},
+	{"invalid_url", utils.ErrInvalidURL},
+	{"address_blocked", safe_dialer.ErrAddressBlocked},
+	{"domain_not_allowed", domain_policy.ErrDomainNotAllowed},
+	{"unknown_credential", credentials.ErrUnknownCredential},
+}
+
+// errCode returns the code of the first known sentinel in the error chain, "" if there is none.
+func errCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, c := range errCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return ""
+}
+
+// storedError is an error restored from the store.
+// It unwraps to the sentinel of its code, so errors.Is keeps working after a restart.
+type storedError struct {
+	msg      string
+	sentinel error
+}
+
+func storedErr(msg, code string) error {
+	if msg == "" {
+		return nil
+	}
+
+	s := &storedError{msg: msg}
+
+	for _, c := range errCodes {
+		if c.code == code {
+			s.sentinel = c.err
+			break
+		}
+	}
+
+	return s
+}
+
+func (s *storedError) Error() string {
+	return s.msg
+}
+
+func (s *storedError) Unwrap() error {
+	return s.sentinel
+}
+
+type nopStore struct{}
+
+func (nopStore) Load() ([]*task_store.Task, error) { return nil, nil }
+func (nopStore) Save(*task_store.Task) error       { return nil }
+func (nopStore) Delete(string) error               { return nil }
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(string, string, *TaskInfo) {}
@@ -1,8 +1,14 @@
 package archiver
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
 )
 
 type TaskStatus int8
@@ -13,81 +19,236 @@ const (
 	StatusArchiving
 	StatusDone
 	StatusError
+	StatusExpired
+	StatusCanceled
+	// StatusArchiveExpired is a done task whose archive was removed by the archive GC
+	StatusArchiveExpired
 )
 
 var (
 	ErrTaskInProgress = errors.New("task already in progress")
 	ErrTaskCompleted  = errors.New("task already completed")
+	ErrTaskExpired    = errors.New("task expired")
+	ErrTaskEmpty      = errors.New("task has no objects")
+	ErrTaskCanceled   = errors.New("task canceled")
 )
 
 type task struct {
 	id string
 
+	// persistMu orders the writes of this task to the store
+	persistMu sync.Mutex
+
 	mu      sync.RWMutex
 	status  TaskStatus
 	objects []object
 
 	zip string
 	err error
+
+	// callbackURL is notified when the task is done or failed
+	callbackURL string
+	// format is empty for the tasks restored from before the formats were added
+	format object_storage.ArchiveFormat
+	// password of an encrypted archive, it is not persisted
+	password string
+	// encrypted is persisted, a restored task has lost its password
+	encrypted bool
+	// manifest adds the metadata files to the archive
+	manifest bool
+	// nameConflict is empty for the tasks restored from before the names were added
+	nameConflict NameConflict
+
+	// updatedAt is the time of the last status change or added object
+	updatedAt time.Time
+
+	// cancel stops the processing of the task, set while it is archiving
+	cancel context.CancelFunc
+	// deleted tasks are no longer written to the store
+	deleted bool
 }
 
 type object struct {
 	src string
-	err error
+	// name and path of the entry set by the client, optional
+	name string
+	path string
+	// auth of the source, its headers are not persisted
+	auth object_storage.SourceAuth
+	// headers is persisted, a restored object has lost its headers
+	headers bool
+	err     error
+	// attempts it took to open the object, 0 - not opened yet
+	attempts int
 }
 
 func newTask(id string, maxObjects int) *task {
 	return &task{
-		id:      id,
-		status:  StatusWaitingForObjects,
-		objects: make([]object, 0, maxObjects),
+		id:        id,
+		status:    StatusWaitingForObjects,
+		objects:   make([]object, 0, maxObjects),
+		updatedAt: time.Now(),
 	}
 }
 
-func (t *task) AddObjects(urls []string, maxObjects int) (int, bool, error) {
-	t.mu.RLock()
+// archiveName is the file name of the archive of the task.
+func (t *task) archiveName() string {
+	return t.id + t.format.Extension()
+}
+
+func (t *task) archiveOptions() object_storage.ArchiveOptions {
+	return object_storage.ArchiveOptions{
+		Format:   t.format,
+		Password: t.password,
+	}
+}
+
+// AddObjects returns the indexes of the accepted objects, the accepted objects are a prefix of objs.
+// An object overwriting an earlier one takes its index and the source of the earlier one is returned in replaced,
+// see NameConflictOverwrite, the rest take new slots.
+func (t *task) AddObjects(objs []NewObject, maxObjects int) (indexes []int, replaced []string, ready bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch t.status {
+	case StatusWaitingForObjects:
+
+	case StatusArchiving:
+		return nil, nil, false, ErrTaskInProgress
+
+	case StatusDone, StatusError, StatusArchiveExpired:
+		return nil, nil, false, ErrTaskCompleted
+
+	case StatusExpired:
+		return nil, nil, false, ErrTaskExpired
 
-	if t.status == StatusWaitingForObjects {
-		t.mu.RUnlock()
+	case StatusCanceled:
+		return nil, nil, false, ErrTaskCanceled
 
-		t.mu.Lock()
-		defer t.mu.Unlock()
+	default:
+		return nil, nil, false, nil
+	}
 
-		free := maxObjects - len(t.objects)
-		var toAdd int
-		if len(urls) > free {
-			toAdd = free
-		} else {
-			toAdd = len(urls)
+	// Objects named by the client by their entry name
+	named := make(map[string]int)
+	for i, o := range t.objects {
+		if o.name != "" {
+			named[o.key()] = i
+		}
+	}
+
+	// The objects are resolved first, so that a conflict leaves the task unchanged
+	objects := make([]object, len(t.objects), maxObjects)
+	copy(objects, t.objects)
+
+	indexes = make([]int, 0, len(objs))
+
+	for _, obj := range objs {
+		o := object{
+			src:     obj.URL,
+			name:    obj.Name,
+			path:    strings.TrimSuffix(obj.Path, "/"),
+			auth:    obj.Auth,
+			headers: len(obj.Auth.Headers) > 0,
 		}
 
-		for i := 0; i < toAdd; i++ {
-			t.objects = append(t.objects, object{src: urls[i]})
+		if o.name != "" {
+			if i, ok := named[o.key()]; ok {
+				switch t.nameConflict {
+				case NameConflictOverwrite:
+					replaced = append(replaced, objects[i].src)
+					objects[i] = o
+					indexes = append(indexes, i)
+
+					continue
+
+				case NameConflictFail:
+					return nil, nil, false, fmt.Errorf("%w: %s", ErrNameConflict, o.key())
+
+				default:
+					for n := 1; ; n++ {
+						o.name = withSuffix(obj.Name, n)
+						if _, ok := named[o.key()]; !ok {
+							break
+						}
+					}
+				}
+			}
 		}
 
-		var ready bool
+		if len(objects) == maxObjects {
+			break
+		}
 
-		if len(t.objects) == maxObjects {
-			t.status = StatusArchiving
-			ready = true
+		if o.name != "" {
+			named[o.key()] = len(objects)
 		}
 
-		return toAdd, ready, nil
+		indexes = append(indexes, len(objects))
+		objects = append(objects, o)
+	}
 
-	} else {
-		defer t.mu.RUnlock()
+	t.objects = objects
+	t.updatedAt = time.Now()
 
-		switch t.status {
-		case StatusArchiving:
-			return 0, false, ErrTaskInProgress
+	if len(t.objects) == maxObjects {
+		t.status = StatusArchiving
+		ready = true
+	}
 
-		case StatusDone, StatusError:
-			return 0, false, ErrTaskCompleted
+	return indexes, replaced, ready, nil
+}
 
-		default:
-			return 0, false, nil
-		}
+// SetCallback replaces the callback URL of a task that has not finished yet.
+func (t *task) SetCallback(callbackURL string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch t.status {
+	case StatusDone, StatusError, StatusArchiveExpired:
+		return ErrTaskCompleted
+
+	case StatusExpired:
+		return ErrTaskExpired
+
+	case StatusCanceled:
+		return ErrTaskCanceled
 	}
+
+	t.callbackURL = callbackURL
+
+	return nil
+}
+
+// Start moves a waiting task with at least one object to StatusArchiving.
+func (t *task) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch t.status {
+	case StatusWaitingForObjects:
+
+	case StatusArchiving:
+		return ErrTaskInProgress
+
+	case StatusDone, StatusError, StatusArchiveExpired:
+		return ErrTaskCompleted
+
+	case StatusExpired:
+		return ErrTaskExpired
+
+	case StatusCanceled:
+		return ErrTaskCanceled
+	}
+
+	if len(t.objects) == 0 {
+		return ErrTaskEmpty
+	}
+
+	t.status = StatusArchiving
+	t.updatedAt = time.Now()
+
+	return nil
 }
 
 func (t *task) Objects() []object {
@@ -99,36 +260,224 @@ func (t *task) Objects() []object {
 	return out
 }
 
+// hasHeaders reports whether any object was added with request headers.
+func (t *task) hasHeaders() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, o := range t.objects {
+		if o.headers {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (t *task) setObjectError(objIndex int, err error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.objects[objIndex].err = err
 }
 
-func (t *task) fail(err error) {
+func (t *task) setObjectAttempts(objIndex int, attempts int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.objects[objIndex].attempts = attempts
+}
+
+func (t *task) resetObjectErrors() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	for i := range t.objects {
+		t.objects[i].err = nil
+		t.objects[i].attempts = 0
+	}
+}
+
+// fail and complete finish an archiving task,
+// they return false if the task has been canceled in the meantime.
+func (t *task) fail(err error) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status != StatusArchiving {
+		return false
+	}
 	t.err = err
 	t.status = StatusError
+	t.updatedAt = time.Now()
+	return true
 }
 
-func (t *task) complete(zip string) {
+func (t *task) complete(zip string) bool {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	if t.status != StatusArchiving {
+		return false
+	}
 	t.zip = zip
 	t.status = StatusDone
+	t.updatedAt = time.Now()
+	return true
+}
+
+// processing returns the context of an archiving task,
+// ok is false if the task has been canceled before processing started.
+func (t *task) processing() (ctx context.Context, cancel context.CancelFunc, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != StatusArchiving {
+		return nil, nil, false
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	t.cancel = cancel
+
+	return ctx, cancel, true
+}
+
+// Cancel moves a waiting or archiving task to StatusCanceled
+// and stops its processing, returns the previous status.
+func (t *task) Cancel() (TaskStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev := t.status
+
+	switch prev {
+	case StatusWaitingForObjects, StatusArchiving:
+
+	case StatusDone, StatusError, StatusArchiveExpired:
+		return prev, ErrTaskCompleted
+
+	case StatusExpired:
+		return prev, ErrTaskExpired
+
+	case StatusCanceled:
+		return prev, ErrTaskCanceled
+	}
+
+	t.status = StatusCanceled
+	t.updatedAt = time.Now()
+
+	if t.cancel != nil {
+		t.cancel()
+	}
+
+	return prev, nil
+}
+
+func (t *task) markDeleted() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deleted = true
+}
+
+func (t *task) isDeleted() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.deleted
+}
+
+// expire moves the task to StatusExpired if it is still in one of the given statuses
+// and has not been updated since deadline, returns the previous status.
+func (t *task) expire(deadline time.Time, from ...TaskStatus) (TaskStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.updatedAt.Before(deadline) {
+		return t.status, false
+	}
+
+	for _, status := range from {
+		if t.status == status {
+			t.status = StatusExpired
+			t.zip = ""
+			t.updatedAt = time.Now()
+
+			return status, true
+		}
+	}
+
+	return t.status, false
+}
+
+// relink replaces the link to the archive of a done task.
+func (t *task) relink(zip string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch t.status {
+	case StatusDone:
+
+	case StatusExpired:
+		return ErrTaskExpired
+
+	case StatusArchiveExpired:
+		return ErrArchiveExpired
+
+	default:
+		return ErrNoArchive
+	}
+
+	t.zip = zip
+
+	return nil
+}
+
+// expireArchive moves a done task to StatusArchiveExpired, its link no longer works.
+func (t *task) expireArchive() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.status != StatusDone {
+		return false
+	}
+
+	t.status = StatusArchiveExpired
+	t.zip = ""
+	t.updatedAt = time.Now()
+
+	return true
+}
+
+// finished reports whether the task is done, failed or canceled.
+func (t *task) finished() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	switch t.status {
+	case StatusDone, StatusError, StatusCanceled:
+		return true
+	}
+
+	return false
+}
+
+func (t *task) lastUpdate() (TaskStatus, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.status, t.updatedAt
 }
 
 type TaskInfo struct {
-	Status  TaskStatus
-	Objects []ObjectInfo
-	Zip     string
-	Err     error
+	Status      TaskStatus
+	Objects     []ObjectInfo
+	Zip         string
+	Err         error
+	CallbackURL string
+	Format      object_storage.ArchiveFormat
+	Encrypted   bool
 }
 
 type ObjectInfo struct {
-	Src string
-	Err error
+	Src      string
+	Name     string
+	Path     string
+	Err      error
+	Attempts int
 }
 
 func (t *task) Info() *TaskInfo {
@@ -138,19 +487,35 @@ func (t *task) Info() *TaskInfo {
 	objs := make([]ObjectInfo, 0, len(t.objects))
 	for _, o := range t.objects {
 		objs = append(objs, ObjectInfo{
-			Src: o.src,
-			Err: o.err,
+			Src:      o.src,
+			Name:     o.name,
+			Path:     o.path,
+			Err:      o.err,
+			Attempts: o.attempts,
 		})
 	}
 
 	return &TaskInfo{
-		Status:  t.status,
-		Objects: objs,
-		Zip:     t.zip,
-		Err:     t.err,
+		Status:      t.status,
+		Objects:     objs,
+		Zip:         t.zip,
+		Err:         t.err,
+		CallbackURL: t.callbackURL,
+		Format:      t.format,
+		Encrypted:   t.encrypted,
 	}
 }
 
+// final statuses don't change anymore, except for expiration.
+func (s TaskStatus) final() bool {
+	switch s {
+	case StatusDone, StatusError, StatusCanceled, StatusExpired, StatusArchiveExpired:
+		return true
+	}
+
+	return false
+}
+
 func (s TaskStatus) String() string {
 	switch s {
 	case StatusWaitingForObjects:
@@ -161,6 +526,12 @@ func (s TaskStatus) String() string {
 		return "Done"
 	case StatusError:
 		return "Error"
+	case StatusExpired:
+		return "Expired"
+	case StatusCanceled:
+		return "Canceled"
+	case StatusArchiveExpired:
+		return "Archive expired"
 	default:
 		return "Unknown"
 	}
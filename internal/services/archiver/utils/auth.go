@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+)
+
+type authKey struct{}
+
+// requestAuth is the auth of one object, carried by the request context to authTransport.
+type requestAuth struct {
+	object_storage.SourceAuth
+	// scheme and host of the object URL, the headers of the client are sent only to them
+	scheme string
+	host   string
+}
+
+func withAuth(ctx context.Context, link string, auth object_storage.SourceAuth) context.Context {
+	if len(auth.Headers) == 0 && auth.Credential == "" {
+		return ctx
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, authKey{}, &requestAuth{SourceAuth: auth, scheme: u.Scheme, host: u.Host})
+}
+
+// authTransport adds the auth headers to every request of an object, redirects included.
+// The headers are set here and not on the request, since http.Client copies
+// the headers of the request to every redirect, whatever host it leads to.
+// Nothing is sent after a redirect from https to http, the headers would go in cleartext.
+type authTransport struct {
+	next        http.RoundTripper
+	credentials *credentials.Store
+}
+
+// withAuthTransport returns a copy of the client that sends the auth of the objects.
+func withAuthTransport(client *http.Client, creds *credentials.Store) *http.Client {
+	c := *client
+
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	c.Transport = &authTransport{next: next, credentials: creds}
+
+	return &c
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth, ok := req.Context().Value(authKey{}).(*requestAuth)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	if strings.EqualFold(auth.scheme, "https") && !strings.EqualFold(req.URL.Scheme, "https") {
+		return t.next.RoundTrip(req)
+	}
+
+	var headers []map[string]string
+
+	if strings.EqualFold(req.URL.Scheme, auth.scheme) && strings.EqualFold(req.URL.Host, auth.host) {
+		headers = append(headers, auth.Headers)
+	}
+
+	if auth.Credential != "" {
+		headers = append(headers, t.credentials.Headers(auth.Credential, req.URL))
+	}
+
+	if len(headers) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+
+	for _, h := range headers {
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
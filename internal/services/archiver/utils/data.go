@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+// maxDataURLSize limits a data URL, it is kept in the task like any other URL
+const maxDataURLSize = 1 << 20
+
+// DataGetter opens RFC 2397 data URLs: data:[<media type>][;base64],<data>
+type DataGetter struct {
+	limits objectLimits
+}
+
+func NewDataGetter(cfg Config) *DataGetter {
+	return &DataGetter{limits: newObjectLimits(cfg)}
+}
+
+func (g *DataGetter) Validate(u *url.URL) error {
+	contentType, data, err := parseDataURL(u.String())
+	if err != nil {
+		return err
+	}
+
+	if err := g.limits.check(int64(len(data))); err != nil {
+		return err
+	}
+
+	return g.limits.checkType(detectContentType(data, contentType))
+}
+
+func (g *DataGetter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	contentType, data, err := parseDataURL(link)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.limits.check(int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	// The data is decoded already, the whole of it is sniffed
+	contentType = detectContentType(data, contentType)
+
+	if err := g.limits.checkType(contentType); err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	return &object_storage.ArchiveObject{
+		Name:        "file_" + time.Now().Format("20060102150405") + extensionByType(mediaType),
+		Time:        time.Now(),
+		Size:        int64(len(data)),
+		Content:     io.NopCloser(bytes.NewReader(data)),
+		ContentType: contentType,
+	}, nil
+}
+
+// parseDataURL returns the content type and the decoded data.
+func parseDataURL(link string) (string, []byte, error) {
+	if len(link) > maxDataURLSize {
+		return "", nil, fmt.Errorf("%w: data url is longer than %d bytes", ErrInvalidURL, maxDataURLSize)
+	}
+
+	scheme, rest, ok := strings.Cut(link, ":")
+	if !ok || !strings.EqualFold(scheme, "data") {
+		return "", nil, fmt.Errorf("%w: not a data url", ErrInvalidURL)
+	}
+
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", nil, fmt.Errorf("%w: data url without a comma", ErrInvalidURL)
+	}
+
+	meta, isBase64 := cutSuffixFold(meta, ";base64")
+
+	// The defaults of RFC 2397
+	switch {
+	case meta == "":
+		meta = "text/plain;charset=US-ASCII"
+	case strings.HasPrefix(meta, ";"):
+		meta = "text/plain" + meta
+	}
+
+	mediaType, params, err := mime.ParseMediaType(meta)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: media type: %s", ErrInvalidURL, err)
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	data := []byte(decoded)
+
+	if isBase64 {
+		// Padding is optional and whitespace is ignored
+		decoded = strings.TrimRight(strings.Join(strings.Fields(decoded), ""), "=")
+
+		data, err = base64.RawStdEncoding.DecodeString(decoded)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: base64: %s", ErrInvalidURL, err)
+		}
+	}
+
+	return mime.FormatMediaType(mediaType, params), data, nil
+}
+
+// preferredExtensions of the types whose first extension in the system mime tables is unusual
+var preferredExtensions = map[string]string{
+	"text/plain": ".txt",
+}
+
+// extensionByType prefers the extension named after the subtype, e.g. ".jpeg" over ".jfif".
+func extensionByType(mediaType string) string {
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+
+	exts, _ := mime.ExtensionsByType(mediaType)
+	if len(exts) == 0 {
+		return ".bin"
+	}
+
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	for _, ext := range exts {
+		if ext == "."+subtype {
+			return ext
+		}
+	}
+
+	return exts[0]
+}
+
+func cutSuffixFold(s, suffix string) (string, bool) {
+	if len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix) {
+		return s[:len(s)-len(suffix)], true
+	}
+
+	return s, false
+}
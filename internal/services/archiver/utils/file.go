@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+var ErrInvalidRoot = errors.New("invalid file root")
+
+// FileGetter opens file:// URLs inside the configured root directories.
+// The files are opened through os.Root, so neither ".." nor a symlink can lead out of the root.
+type FileGetter struct {
+	roots  []fileRoot
+	limits objectLimits
+}
+
+type fileRoot struct {
+	// dir is absolute and clean without the trailing slash, it is the URL path prefix of the root
+	dir  string
+	root *os.Root
+}
+
+// NewFileGetter returns error:
+//   - ErrInvalidRoot
+func NewFileGetter(dirs []string, cfg Config) (*FileGetter, error) {
+	g := &FileGetter{limits: newObjectLimits(cfg)}
+
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidRoot, dir, err)
+		}
+
+		root, err := os.OpenRoot(abs)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRoot, err)
+		}
+
+		g.roots = append(g.roots, fileRoot{dir: strings.TrimSuffix(filepath.ToSlash(abs), "/"), root: root})
+	}
+
+	return g, nil
+}
+
+// Validate rejects the URLs outside of the roots.
+func (g *FileGetter) Validate(u *url.URL) error {
+	_, _, err := g.resolve(u)
+	return err
+}
+
+func (g *FileGetter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	root, name, err := g.resolve(u)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, fileError(err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fileError(err)
+	}
+
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return nil, fmt.Errorf("%w: not a regular file", ErrBadRequest)
+	}
+
+	if err := g.limits.check(info.Size()); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	obj := &object_storage.ArchiveObject{
+		Name:        path.Base(name),
+		Time:        info.ModTime(),
+		Size:        info.Size(),
+		Content:     g.limits.limit(f),
+		URL:         link,
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+	}
+
+	if err := g.limits.sniff(obj); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// resolve returns the root of the URL path and the path inside it.
+func (g *FileGetter) resolve(u *url.URL) (*os.Root, string, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, "", fmt.Errorf("%w: file url with host %q", ErrInvalidURL, u.Host)
+	}
+
+	p := path.Clean(u.Path)
+	if !path.IsAbs(p) {
+		return nil, "", fmt.Errorf("%w: relative file path", ErrInvalidURL)
+	}
+
+	for _, r := range g.roots {
+		if name, ok := strings.CutPrefix(p, r.dir+"/"); ok {
+			return r.root, name, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("%w: %s is outside of the file roots", ErrAccessDenied, p)
+}
+
+func fileError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("%w: %s", ErrFileNotFound, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("%w: %s", ErrAccessDenied, err)
+	}
+
+	// os.Root rejects the paths escaping it, e.g. through a symlink
+	return fmt.Errorf("%w: %s", ErrAccessDenied, err)
+}
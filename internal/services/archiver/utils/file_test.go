@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileGetter(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "a.pdf"), []byte("%PDF-1.4 pdf content"), 0o644))
+
+	// Outside of the root, reachable through "..", a sibling with the same prefix and a symlink
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret.pdf"), []byte("secret"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "root-other"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "root-other", "b.pdf"), []byte("other"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(dir, "secret.pdf"), filepath.Join(root, "link.pdf")))
+
+	getter, err := NewFileGetter([]string{root + "/"}, Config{ValidContentTypes: []string{"application/pdf"}})
+	require.NoError(t, err)
+
+	fileURL := func(p string) string {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String()
+	}
+
+	t.Run("inside the root", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), fileURL(filepath.Join(root, "docs", "a.pdf")), object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "a.pdf", obj.Name)
+		require.Equal(t, "application/pdf", obj.ContentType)
+		require.Equal(t, int64(len("%PDF-1.4 pdf content")), obj.Size)
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	t.Run("localhost", func(t *testing.T) {
+		link := "file://localhost" + filepath.ToSlash(filepath.Join(root, "docs", "a.pdf"))
+		obj, err := getter.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	tests := []struct {
+		name string
+		link string
+		err  error
+	}{
+		{"dot-dot", fileURL(root) + "/docs/../../secret.pdf", ErrAccessDenied},
+		{"sibling prefix", fileURL(filepath.Join(dir, "root-other", "b.pdf")), ErrAccessDenied},
+		{"the root itself", fileURL(root), ErrAccessDenied},
+		{"symlink escape", fileURL(filepath.Join(root, "link.pdf")), ErrAccessDenied},
+		{"not found", fileURL(filepath.Join(root, "docs", "missing.pdf")), ErrFileNotFound},
+		{"directory", fileURL(filepath.Join(root, "docs")), ErrBadRequest},
+		{"remote host", "file://example.com" + filepath.ToSlash(filepath.Join(root, "docs", "a.pdf")), ErrInvalidURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := getter.ToLink(context.Background(), tt.link, object_storage.SourceAuth{})
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("validate", func(t *testing.T) {
+		u, _ := url.Parse(fileURL(filepath.Join(dir, "secret.pdf")))
+		require.ErrorIs(t, getter.Validate(u), ErrAccessDenied)
+
+		u, _ = url.Parse(fileURL(filepath.Join(root, "docs", "a.pdf")))
+		require.NoError(t, getter.Validate(u))
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes"), 0o644))
+
+		_, err := getter.ToLink(context.Background(), fileURL(filepath.Join(root, "notes.txt")), object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrIncorrectFormat)
+	})
+
+	t.Run("invalid root", func(t *testing.T) {
+		_, err := NewFileGetter([]string{filepath.Join(dir, "missing")}, Config{})
+		require.ErrorIs(t, err, ErrInvalidRoot)
+	})
+}
@@ -0,0 +1,383 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+)
+
+const (
+	defaultFTPPort    = "21"
+	defaultFTPTimeout = 30 * time.Second
+)
+
+// Dialer opens the connections of the getters that don't speak HTTP,
+// e.g. safe_dialer.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// FTPGetter opens ftp:// URLs in passive binary mode.
+// The login is taken from the URL or from the "Authorization: Basic" header
+// of the object or of its credential, anonymous otherwise.
+// The data connection goes to the address of the control connection whatever
+// the server announces, so the server can't point it at another host (FTP bounce).
+type FTPGetter struct {
+	dialer      Dialer
+	timeout     time.Duration
+	limits      objectLimits
+	domains     *domain_policy.Policy
+	credentials *credentials.Store
+}
+
+// NewFTPGetter uses timeout for the commands, the data transfer is not limited, 0 - default.
+func NewFTPGetter(dialer Dialer, timeout time.Duration, cfg Config) *FTPGetter {
+	if timeout <= 0 {
+		timeout = defaultFTPTimeout
+	}
+
+	return &FTPGetter{
+		dialer:      dialer,
+		timeout:     timeout,
+		limits:      newObjectLimits(cfg),
+		domains:     cfg.Domains,
+		credentials: cfg.Credentials,
+	}
+}
+
+// Validate rejects the URLs without a host or a file path.
+func (g *FTPGetter) Validate(u *url.URL) error {
+	if u.Host == "" {
+		return fmt.Errorf("%w: no host", ErrInvalidURL)
+	}
+
+	if ftpPath(u) == "" {
+		return fmt.Errorf("%w: no file path", ErrInvalidURL)
+	}
+
+	return nil
+}
+
+func (g *FTPGetter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	if err := g.Validate(u); err != nil {
+		return nil, err
+	}
+
+	if err := g.domains.Check(u.Hostname()); err != nil {
+		return nil, err
+	}
+
+	if auth.Credential != "" {
+		if err := g.credentials.Check(auth.Credential, link); err != nil {
+			return nil, err
+		}
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = defaultFTPPort
+	}
+
+	conn, err := g.dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, fmt.Errorf("ftp connect failed: %w", err)
+	}
+
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn), timeout: g.timeout}
+
+	// The connection is closed if the task is canceled while it is in use
+	stop := context.AfterFunc(ctx, func() { c.text.Close() })
+
+	obj, err := g.retrieve(ctx, c, u, auth)
+	if err != nil {
+		stop()
+		c.text.Close()
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
+	}
+
+	obj.Content = g.limits.limit(&ftpBody{data: obj.Content, size: obj.Size, conn: c, stop: stop})
+
+	if err := g.limits.sniff(obj); err != nil {
+		obj.Content.Close()
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+func (g *FTPGetter) retrieve(ctx context.Context, c *ftpConn, u *url.URL, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	if _, _, err := c.expect(220); err != nil {
+		return nil, err
+	}
+
+	user, pass := g.login(u, auth)
+
+	code, err := c.cmd("USER "+user, 230, 331)
+	if err != nil {
+		return nil, err
+	}
+	if code == 331 {
+		if _, err := c.cmd("PASS "+pass, 230, 202); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := c.cmd("TYPE I", 200); err != nil {
+		return nil, err
+	}
+
+	name := ftpPath(u)
+
+	// SIZE is an extension, without it the size is unknown
+	size := int64(-1)
+	if _, msg, err := c.do("SIZE "+name, 213); err == nil {
+		if n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err == nil {
+			size = n
+		}
+	} else if errors.Is(err, ErrFileNotFound) {
+		return nil, err
+	}
+
+	if err := g.limits.check(size); err != nil {
+		return nil, err
+	}
+
+	port, err := c.passivePort()
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := g.dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, fmt.Errorf("ftp data connect failed: %w", err)
+	}
+
+	if _, err := c.cmd("RETR "+name, 125, 150); err != nil {
+		data.Close()
+		return nil, err
+	}
+
+	// The transfer itself is not limited by the command timeout
+	c.conn.SetDeadline(time.Time{})
+
+	return &object_storage.ArchiveObject{
+		Name:        path.Base(name),
+		Time:        time.Now(),
+		Size:        size,
+		Content:     data,
+		URL:         redactedURL(u),
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+	}, nil
+}
+
+func (g *FTPGetter) login(u *url.URL, auth object_storage.SourceAuth) (user, pass string) {
+	if u.User != nil {
+		pass, _ = u.User.Password()
+		return u.User.Username(), pass
+	}
+
+	if user, pass, ok := basicAuth(auth.Headers); ok {
+		return user, pass
+	}
+
+	if auth.Credential != "" {
+		if user, pass, ok := basicAuth(g.credentials.Headers(auth.Credential, u)); ok {
+			return user, pass
+		}
+	}
+
+	return "anonymous", "anonymous@"
+}
+
+// basicAuth parses the "Authorization: Basic" header.
+func basicAuth(headers map[string]string) (user, pass string, ok bool) {
+	for name, value := range headers {
+		if textproto.CanonicalMIMEHeaderKey(name) != "Authorization" {
+			continue
+		}
+
+		req := http.Request{Header: http.Header{"Authorization": {value}}}
+
+		return req.BasicAuth()
+	}
+
+	return "", "", false
+}
+
+// ftpPath is the path of the file relative to the login directory, as in RFC 1738.
+func ftpPath(u *url.URL) string {
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// redactedURL drops the password, the URL ends up in the manifest.
+func redactedURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+
+	c := *u
+	c.User = url.User(u.User.Username())
+
+	return c.String()
+}
+
+type ftpConn struct {
+	conn    net.Conn
+	text    *textproto.Conn
+	timeout time.Duration
+}
+
+// cmd sends the command and expects one of the codes.
+func (c *ftpConn) cmd(command string, codes ...int) (int, error) {
+	code, _, err := c.do(command, codes...)
+	return code, err
+}
+
+func (c *ftpConn) do(command string, codes ...int) (int, string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if err := c.text.PrintfLine("%s", command); err != nil {
+		return 0, "", err
+	}
+
+	code, msg, err := c.expect(codes...)
+
+	return code, msg, err
+}
+
+// expect reads a reply, the codes other than the expected ones are mapped to the getter errors.
+func (c *ftpConn) expect(codes ...int) (int, string, error) {
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	code, msg, err := c.text.ReadResponse(0)
+	if err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) {
+			return 0, "", err
+		}
+	}
+
+	for _, expected := range codes {
+		if code == expected {
+			return code, msg, nil
+		}
+	}
+
+	return code, msg, ftpError(code, msg)
+}
+
+// passivePort asks for the data port with EPSV and falls back to PASV.
+func (c *ftpConn) passivePort() (string, error) {
+	if _, msg, err := c.do("EPSV", 229); err == nil {
+		// 229 Entering Extended Passive Mode (|||port|)
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start >= 0 && end > start+4 {
+			return msg[start+4 : end], nil
+		}
+	}
+
+	_, msg, err := c.do("PASV", 227)
+	if err != nil {
+		return "", err
+	}
+
+	// 227 Entering Passive Mode (h1,h2,h3,h4,p1,p2), the host is ignored
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("%w: ftp reply %q", ErrInternalSourceError, msg)
+	}
+
+	fields := strings.Split(msg[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("%w: ftp reply %q", ErrInternalSourceError, msg)
+	}
+
+	p1, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("%w: ftp reply %q", ErrInternalSourceError, msg)
+	}
+
+	return strconv.Itoa(p1<<8 | p2), nil
+}
+
+func ftpError(code int, msg string) error {
+	err := fmt.Errorf("ftp reply %d %s", code, msg)
+
+	switch {
+	case code == 530 || code == 332:
+		return fmt.Errorf("%w: %w", ErrAuthenticationRequired, err)
+	case code == 550 || code == 450:
+		return fmt.Errorf("%w: %w", ErrFileNotFound, err)
+	case code >= 400 && code < 500:
+		return fmt.Errorf("%w: %w", ErrInternalSourceError, err)
+	}
+
+	return fmt.Errorf("%w: %w", ErrBadRequest, err)
+}
+
+// ftpBody closes the data connection and then ends the session.
+type ftpBody struct {
+	data io.ReadCloser
+	// size reported by SIZE, -1 if unknown, a shorter transfer is an error
+	size int64
+	read int64
+	conn *ftpConn
+	stop func() bool
+}
+
+func (b *ftpBody) Read(p []byte) (int, error) {
+	n, err := b.data.Read(p)
+	b.read += int64(n)
+
+	if err == io.EOF && b.size >= 0 && b.read < b.size {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+func (b *ftpBody) Close() error {
+	err := b.data.Close()
+
+	b.stop()
+
+	// 226 Transfer complete, or an abort reply if the body was not read to the end
+	b.conn.expect(226, 250)
+	b.conn.cmd("QUIT", 221)
+
+	if closeErr := b.conn.text.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/stretchr/testify/require"
+)
+
+// ftpServer is a stand-in FTP server with a single user and the files in memory.
+type ftpServer struct {
+	ln       net.Listener
+	files    map[string]string
+	user     string
+	pass     string
+	noEPSV   bool
+	noSIZE   bool
+	mu       sync.Mutex
+	logins   []string
+	commands []string
+}
+
+func newFTPServer(t *testing.T, files map[string]string) *ftpServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &ftpServer{ln: ln, files: files}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *ftpServer) url(p string) string {
+	return "ftp://" + s.ln.Addr().String() + p
+}
+
+func (s *ftpServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 ready")
+
+	var user string
+	var data net.Listener
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+
+		switch cmd {
+		case "USER":
+			user = arg
+			reply("331 password please")
+
+		case "PASS":
+			s.mu.Lock()
+			s.logins = append(s.logins, user+":"+arg)
+			s.mu.Unlock()
+
+			if s.user != "" && (user != s.user || arg != s.pass) {
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+
+		case "TYPE":
+			reply("200 type set")
+
+		case "SIZE":
+			content, ok := s.files[arg]
+			switch {
+			case s.noSIZE:
+				reply("502 not implemented")
+			case !ok:
+				reply("550 no such file")
+			default:
+				reply("213 %d", len(content))
+			}
+
+		case "EPSV", "PASV":
+			if cmd == "EPSV" && s.noEPSV {
+				reply("502 not implemented")
+				continue
+			}
+
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				reply("425 can't open data connection")
+				continue
+			}
+
+			port := data.Addr().(*net.TCPAddr).Port
+			if cmd == "EPSV" {
+				reply("229 Entering Extended Passive Mode (|||%d|)", port)
+			} else {
+				// The announced host is bogus, the client must use the control connection address
+				reply("227 Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff)
+			}
+
+		case "RETR":
+			content, ok := s.files[arg]
+			if !ok || data == nil {
+				reply("550 no such file")
+				continue
+			}
+
+			reply("150 opening data connection")
+
+			dc, err := data.Accept()
+			data.Close()
+			data = nil
+			if err != nil {
+				return
+			}
+
+			io.WriteString(dc, content)
+			dc.Close()
+
+			reply("226 transfer complete")
+
+		case "QUIT":
+			reply("221 bye")
+			return
+
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestFTPGetter(t *testing.T) {
+	server := newFTPServer(t, map[string]string{
+		"docs/a.pdf": "%PDF-1.4 pdf content",
+	})
+
+	getter := NewFTPGetter(&net.Dialer{}, time.Second, Config{})
+
+	t.Run("anonymous", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), server.url("/docs/a.pdf"), object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "a.pdf", obj.Name)
+		require.Equal(t, "application/pdf", obj.ContentType)
+		require.Equal(t, int64(len("%PDF-1.4 pdf content")), obj.Size)
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		require.Equal(t, "anonymous:anonymous@", server.logins[len(server.logins)-1])
+		require.Contains(t, server.commands, "EPSV")
+		require.Equal(t, "QUIT", server.commands[len(server.commands)-1])
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), server.url("/docs/missing.pdf"), object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileNotFound)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), server.url("/"), object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrInvalidURL)
+	})
+}
+
+func TestFTPGetter_Login(t *testing.T) {
+	server := newFTPServer(t, map[string]string{"a.pdf": "%PDF-1.4 pdf content"})
+	server.user, server.pass = "user", "secret"
+
+	getter := NewFTPGetter(&net.Dialer{}, time.Second, Config{})
+
+	t.Run("url userinfo", func(t *testing.T) {
+		link := strings.Replace(server.url("/a.pdf"), "ftp://", "ftp://user:secret@", 1)
+
+		obj, err := getter.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.NotContains(t, obj.URL, "secret")
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	t.Run("basic auth header", func(t *testing.T) {
+		// user:secret
+		auth := object_storage.SourceAuth{Headers: map[string]string{"authorization": "Basic dXNlcjpzZWNyZXQ="}}
+
+		obj, err := getter.ToLink(context.Background(), server.url("/a.pdf"), auth)
+		require.NoError(t, err)
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		link := strings.Replace(server.url("/a.pdf"), "ftp://", "ftp://user:wrong@", 1)
+
+		_, err := getter.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrAuthenticationRequired)
+	})
+}
+
+func TestFTPGetter_PASV(t *testing.T) {
+	server := newFTPServer(t, map[string]string{"a.pdf": "%PDF-1.4 " + strings.Repeat("x", sniffSize)})
+	server.noEPSV, server.noSIZE = true, true
+
+	getter := NewFTPGetter(&net.Dialer{}, time.Second, Config{MaxObjectSize: sniffSize})
+
+	obj, err := getter.ToLink(context.Background(), server.url("/a.pdf"), object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), obj.Size)
+
+	// Without SIZE the limit is checked while reading
+	_, err = io.ReadAll(obj.Content)
+	require.ErrorIs(t, err, ErrFileTooLarge)
+	require.NoError(t, obj.Content.Close())
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	require.Contains(t, server.commands, "PASV")
+}
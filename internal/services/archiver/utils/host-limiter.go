@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimits bound the load on one source host, so that a large task
+// doesn't get the service banned by a CDN. 0 - no limit.
+type HostLimits struct {
+	// MaxConns is the number of objects of one host downloaded at the same time.
+	MaxConns int
+	// RequestsPerSecond limits the rate of requests to one host, retries included.
+	RequestsPerSecond float64
+}
+
+// idleHosts is the number of tracked hosts after which the idle ones are forgotten
+const idleHosts = 1024
+
+type hostLimiter struct {
+	limits HostLimits
+	// interval between two requests to the same host, 0 - no rate limit
+	interval time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+type hostState struct {
+	// conns is nil without the connection limit
+	conns chan struct{}
+	// users holding or waiting for a connection, the state is kept while there are any
+	users int
+	// next is the earliest time of the next request
+	next time.Time
+}
+
+func newHostLimiter(limits HostLimits) *hostLimiter {
+	l := &hostLimiter{
+		limits: limits,
+		hosts:  make(map[string]*hostState),
+	}
+
+	if limits.RequestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / limits.RequestsPerSecond)
+	}
+
+	return l
+}
+
+// acquire takes a connection slot of the host of link, release frees it.
+func (l *hostLimiter) acquire(ctx context.Context, link string) (func(), error) {
+	host := hostOf(link)
+	if l.limits.MaxConns <= 0 || host == "" {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	st := l.state(host)
+	st.users++
+	l.mu.Unlock()
+
+	done := func() {
+		l.mu.Lock()
+		st.users--
+		l.mu.Unlock()
+	}
+
+	select {
+	case st.conns <- struct{}{}:
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			<-st.conns
+			done()
+		})
+	}, nil
+}
+
+// wait blocks until a request to the host of link is allowed by the rate limit.
+func (l *hostLimiter) wait(ctx context.Context, link string) error {
+	host := hostOf(link)
+	if l.interval <= 0 || host == "" {
+		return nil
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	st := l.state(host)
+	slot := st.next
+	if slot.Before(now) {
+		slot = now
+	}
+	st.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleep(ctx, slot.Sub(now))
+}
+
+// state must be called with l.mu held.
+func (l *hostLimiter) state(host string) *hostState {
+	st, ok := l.hosts[host]
+	if ok {
+		return st
+	}
+
+	if len(l.hosts) >= idleHosts {
+		l.forgetIdle(time.Now())
+	}
+
+	st = &hostState{}
+	if l.limits.MaxConns > 0 {
+		st.conns = make(chan struct{}, l.limits.MaxConns)
+	}
+	l.hosts[host] = st
+
+	return st
+}
+
+// forgetIdle removes the hosts without connections and pending requests.
+func (l *hostLimiter) forgetIdle(now time.Time) {
+	for host, st := range l.hosts {
+		if st.users == 0 && !st.next.After(now) {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// hostOf returns the host the limits apply to, "" for the schemes without a network host,
+// e.g. data: or upload:, they are not limited.
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp":
+	default:
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+// objectLimits are checked by the getters of all schemes.
+type objectLimits struct {
+	// validContentTypes are lower case media types without parameters, "image/*" is a wildcard,
+	// nil if any content type is valid
+	validContentTypes []string
+	// maxObjectSize is 0 without the limit
+	maxObjectSize int64
+}
+
+func newObjectLimits(cfg Config) objectLimits {
+	var valid []string
+
+	for _, contentType := range cfg.ValidContentTypes {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType = strings.ToLower(strings.TrimSpace(contentType))
+		}
+
+		valid = append(valid, mediaType)
+	}
+
+	return objectLimits{
+		validContentTypes: valid,
+		maxObjectSize:     cfg.MaxObjectSize,
+	}
+}
+
+// check returns ErrFileTooLarge, size is -1 if unknown.
+// The content type is checked after it is detected from the content, see sniff.
+func (l objectLimits) check(size int64) error {
+	// An unknown size is checked while reading, see limit
+	if l.maxObjectSize > 0 && size > l.maxObjectSize {
+		return fmt.Errorf("%w: %d bytes", ErrFileTooLarge, size)
+	}
+
+	return nil
+}
+
+// checkType returns ErrIncorrectFormat if the content type is not valid, the parameters are ignored.
+func (l objectLimits) checkType(contentType string) error {
+	if l.validContentTypes != nil && !matchContentType(contentType, l.validContentTypes) {
+		return fmt.Errorf("%w: %s", ErrIncorrectFormat, contentType)
+	}
+
+	return nil
+}
+
+// limit returns the content that fails with ErrFileTooLarge once it is over the limit.
+func (l objectLimits) limit(content io.ReadCloser) io.ReadCloser {
+	if l.maxObjectSize <= 0 {
+		return content
+	}
+
+	return &limitedBody{ReadCloser: content, remaining: l.maxObjectSize}
+}
+
+// limitedBody fails with ErrFileTooLarge once more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrFileTooLarge
+	}
+
+	// Read one byte over the limit to tell "exactly at the limit" from "over it"
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+
+	if l.remaining < 0 {
+		return n + int(l.remaining), ErrFileTooLarge
+	}
+
+	return n, err
+}
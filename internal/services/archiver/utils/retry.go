@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how transient source failures are retried:
+// network errors, 429 and 5xx responses.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, 0 or 1 - no retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second attempt,
+	// every next delay is doubled up to MaxBackoff. A random jitter of up to half
+	// of the delay is subtracted, so that failed objects don't retry in lockstep.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// AttemptError is returned by ToLink when the object could not be opened,
+// it reports how many attempts were made.
+type AttemptError struct {
+	Err      error
+	attempts int
+}
+
+func (e *AttemptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AttemptError) Attempts() int {
+	return e.attempts
+}
+
+// transientError marks a failure worth retrying.
+type transientError struct {
+	err error
+	// retryAfter is the delay requested by the source, 0 - not set
+	retryAfter time.Duration
+}
+
+func (t *transientError) Error() string {
+	return t.err.Error()
+}
+
+func (t *transientError) Unwrap() error {
+	return t.err
+}
+
+// backoff returns the delay before the next attempt and false if the object should not be retried.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	var transient *transientError
+	if attempt >= p.MaxAttempts || !errors.As(err, &transient) {
+		return 0, false
+	}
+
+	delay := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	if delay > 0 {
+		delay -= rand.N(delay/2 + 1)
+	}
+
+	if transient.retryAfter > delay {
+		// Waiting longer than MaxBackoff would hold the download slot for too long
+		if p.MaxBackoff > 0 && transient.retryAfter > p.MaxBackoff {
+			return 0, false
+		}
+
+		delay = transient.retryAfter
+	}
+
+	return delay, true
+}
+
+// parseRetryAfter parses the Retry-After header: delay in seconds or HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
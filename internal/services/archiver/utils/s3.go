@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/sigv4"
+)
+
+var ErrInvalidS3Config = errors.New("invalid s3 source config")
+
+type S3Config struct {
+	// Endpoint is the base URL of the S3 API, e.g. https://s3.eu-central-1.amazonaws.com
+	Endpoint string
+	Region   string
+
+	// AccessKey and SecretKey sign the requests, empty - the objects are read anonymously.
+	AccessKey string
+	SecretKey string
+
+	// PathStyle addresses objects as endpoint/bucket/key instead of bucket.endpoint/key.
+	PathStyle bool
+
+	// Buckets the objects can be read from, empty - any bucket.
+	Buckets []string
+}
+
+// S3Getter opens s3://bucket/key URLs with a GET request to the S3 API.
+type S3Getter struct {
+	cfg      S3Config
+	client   *http.Client
+	endpoint *url.URL
+	signer   *sigv4.Signer
+	buckets  map[string]struct{}
+	limits   objectLimits
+}
+
+// NewS3Getter returns error:
+//   - ErrInvalidS3Config
+func NewS3Getter(client *http.Client, s3cfg S3Config, cfg Config) (*S3Getter, error) {
+	endpoint, err := url.Parse(s3cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("%w: endpoint: %s", ErrInvalidS3Config, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" || endpoint.Host == "" {
+		return nil, fmt.Errorf("%w: endpoint must be an absolute http(s) URL", ErrInvalidS3Config)
+	}
+
+	if (s3cfg.AccessKey == "") != (s3cfg.SecretKey == "") {
+		return nil, fmt.Errorf("%w: both access and secret keys are needed", ErrInvalidS3Config)
+	}
+
+	if s3cfg.Region == "" {
+		s3cfg.Region = "us-east-1"
+	}
+
+	g := &S3Getter{
+		cfg:      s3cfg,
+		client:   client,
+		endpoint: endpoint,
+		limits:   newObjectLimits(cfg),
+	}
+
+	if s3cfg.AccessKey != "" {
+		g.signer = &sigv4.Signer{
+			AccessKey: s3cfg.AccessKey,
+			SecretKey: s3cfg.SecretKey,
+			Region:    s3cfg.Region,
+		}
+	}
+
+	if len(s3cfg.Buckets) > 0 {
+		g.buckets = make(map[string]struct{}, len(s3cfg.Buckets))
+
+		for _, bucket := range s3cfg.Buckets {
+			g.buckets[bucket] = struct{}{}
+		}
+	}
+
+	return g, nil
+}
+
+// Validate rejects the URLs without a bucket or a key and the buckets that are not allowed.
+func (g *S3Getter) Validate(u *url.URL) error {
+	_, _, err := g.split(u)
+	return err
+}
+
+func (g *S3Getter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	bucket, key, err := g.split(u)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.objectURL(bucket, key).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request failed: %w", err)
+	}
+
+	if g.signer != nil {
+		g.signer.Sign(req, sigv4.UnsignedPayload, time.Now())
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if err := g.checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	modified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		modified = time.Now()
+	}
+
+	obj := &object_storage.ArchiveObject{
+		Name:        path.Base(key),
+		Time:        modified,
+		Size:        resp.ContentLength,
+		Content:     g.limits.limit(resp.Body),
+		URL:         link,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+
+	if err := g.limits.sniff(obj); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// split returns the bucket and the key of an s3:// URL.
+func (g *S3Getter) split(u *url.URL) (bucket, key string, err error) {
+	bucket, key = u.Host, strings.TrimPrefix(u.Path, "/")
+
+	if bucket == "" {
+		return "", "", fmt.Errorf("%w: no bucket", ErrInvalidURL)
+	}
+
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", "", fmt.Errorf("%w: no object key", ErrInvalidURL)
+	}
+
+	if g.buckets != nil {
+		if _, ok := g.buckets[bucket]; !ok {
+			return "", "", fmt.Errorf("%w: bucket %q is not allowed", ErrAccessDenied, bucket)
+		}
+	}
+
+	return bucket, key, nil
+}
+
+func (g *S3Getter) objectURL(bucket, key string) *url.URL {
+	u := *g.endpoint
+
+	if g.cfg.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+
+	return &u
+}
+
+func (g *S3Getter) checkResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrFileNotFound
+
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrAuthenticationRequired
+
+	case resp.StatusCode == http.StatusForbidden:
+		return ErrAccessDenied
+
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("%w: s3 status %d", ErrInternalSourceError, resp.StatusCode)
+
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("%w: s3 status %d", ErrBadRequest, resp.StatusCode)
+	}
+
+	return g.limits.check(resp.ContentLength)
+}
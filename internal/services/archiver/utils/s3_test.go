@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/sigv4"
+	"github.com/stretchr/testify/require"
+)
+
+// s3Stub serves GetObject in path style and checks the SigV4 signature of every request.
+func s3Stub(t *testing.T, signer *sigv4.Signer, objects map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if signer != nil && !checkS3Signature(signer, r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		content, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func checkS3Signature(signer *sigv4.Signer, r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+
+	date, err := time.Parse(sigv4.AmzDateFormat, r.Header.Get(sigv4.HeaderDate))
+	if err != nil {
+		return false
+	}
+
+	_, signedHeaders, _ := strings.Cut(auth, "SignedHeaders=")
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+
+	u := *r.URL
+	u.Scheme = "http"
+	u.Host = r.Host
+
+	check, _ := http.NewRequest(r.Method, u.String(), nil)
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			check.Header.Set(name, r.Header.Get(name))
+		}
+	}
+
+	signer.Sign(check, r.Header.Get(sigv4.HeaderContentSHA256), date)
+
+	return check.Header.Get("Authorization") == auth
+}
+
+func TestS3Getter(t *testing.T) {
+	signer := &sigv4.Signer{AccessKey: "access", SecretKey: "secret", Region: "eu-central-1"}
+	server := s3Stub(t, signer, map[string]string{
+		"docs/reports/2025 q1.pdf": "%PDF-1.4 pdf content",
+	})
+
+	s3Cfg := S3Config{
+		Endpoint:  server.URL,
+		Region:    "eu-central-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		PathStyle: true,
+		Buckets:   []string{"docs"},
+	}
+
+	getter, err := NewS3Getter(&http.Client{}, s3Cfg, Config{})
+	require.NoError(t, err)
+
+	t.Run("get object", func(t *testing.T) {
+		link := "s3://docs/reports/2025%20q1.pdf"
+
+		obj, err := getter.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "2025 q1.pdf", obj.Name)
+		require.Equal(t, link, obj.URL)
+		require.Equal(t, "application/pdf", obj.ContentType)
+		require.Equal(t, 2006, obj.Time.Year())
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), "s3://docs/missing.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileNotFound)
+	})
+
+	t.Run("bucket not allowed", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), "s3://other/a.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrAccessDenied)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		cfg := s3Cfg
+		cfg.SecretKey = "wrong"
+
+		getter, err := NewS3Getter(&http.Client{}, cfg, Config{})
+		require.NoError(t, err)
+
+		_, err = getter.ToLink(context.Background(), "s3://docs/reports/2025%20q1.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrAccessDenied)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		for _, link := range []string{"s3:///a.pdf", "s3://docs", "s3://docs/dir/"} {
+			u, _ := url.Parse(link)
+			require.ErrorIs(t, getter.Validate(u), ErrInvalidURL, link)
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := NewS3Getter(&http.Client{}, S3Config{Endpoint: "localhost:9000"}, Config{})
+		require.ErrorIs(t, err, ErrInvalidS3Config)
+
+		_, err = NewS3Getter(&http.Client{}, S3Config{Endpoint: server.URL, AccessKey: "access"}, Config{})
+		require.ErrorIs(t, err, ErrInvalidS3Config)
+	})
+}
+
+func TestS3Getter_Anonymous(t *testing.T) {
+	server := s3Stub(t, nil, map[string]string{"public/a.pdf": "%PDF-1.4 pdf content"})
+
+	getter, err := NewS3Getter(&http.Client{}, S3Config{Endpoint: server.URL, PathStyle: true}, Config{})
+	require.NoError(t, err)
+
+	obj, err := getter.ToLink(context.Background(), "s3://public/a.pdf", object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+var (
+	ErrUnsupportedScheme = errors.New("unsupported scheme")
+	ErrInvalidURL        = errors.New("invalid url")
+)
+
+// Getter opens the objects of the URL schemes it is registered for.
+type Getter interface {
+	ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error)
+}
+
+// validator is implemented by the getters that can reject a URL before it is added to a task.
+type validator interface {
+	Validate(u *url.URL) error
+}
+
+// hostAcquirer is implemented by the getters that limit the downloads per source host.
+type hostAcquirer interface {
+	AcquireHost(ctx context.Context, link string) (release func(), err error)
+}
+
+// taskReleaser is implemented by the getters that keep the objects of the tasks, e.g. Uploads.
+type taskReleaser interface {
+	ReleaseTask(taskID string) error
+}
+
+// Schemes routes every object to the getter of its URL scheme.
+type Schemes struct {
+	getters map[string]Getter
+	// internal schemes are opened, but not accepted from the clients
+	internal map[string]struct{}
+}
+
+func NewSchemes() *Schemes {
+	return &Schemes{
+		getters:  make(map[string]Getter),
+		internal: make(map[string]struct{}),
+	}
+}
+
+// Register makes the getter open the URLs of the schemes, a later getter replaces an earlier one.
+func (s *Schemes) Register(g Getter, schemes ...string) {
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(scheme)
+		s.getters[scheme] = g
+		delete(s.internal, scheme)
+	}
+}
+
+// RegisterInternal registers the getter of the links made by the service itself:
+// Validate rejects them with ErrUnsupportedScheme.
+func (s *Schemes) RegisterInternal(g Getter, schemes ...string) {
+	s.Register(g, schemes...)
+
+	for _, scheme := range schemes {
+		s.internal[strings.ToLower(scheme)] = struct{}{}
+	}
+}
+
+// Validate returns error:
+//   - ErrUnsupportedScheme
+//   - ErrInvalidURL
+//   - the error of the getter of the scheme
+func (s *Schemes) Validate(link string) error {
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+
+	g, ok := s.getters[scheme]
+	if _, internal := s.internal[scheme]; !ok || internal {
+		return fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+
+	if v, ok := g.(validator); ok {
+		return v.Validate(u)
+	}
+
+	return nil
+}
+
+// ToLink opens the object with the getter of its scheme.
+func (s *Schemes) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	g, err := s.getter(link)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.ToLink(ctx, link, auth)
+}
+
+// AcquireHost takes a slot of the source host if the getter of the scheme limits them.
+func (s *Schemes) AcquireHost(ctx context.Context, link string) (func(), error) {
+	g, err := s.getter(link)
+	if err != nil {
+		return func() {}, nil
+	}
+
+	if h, ok := g.(hostAcquirer); ok {
+		return h.AcquireHost(ctx, link)
+	}
+
+	return func() {}, nil
+}
+
+// ReleaseTask lets the getters that keep the objects of the tasks free those of the finished task.
+func (s *Schemes) ReleaseTask(taskID string) error {
+	released := make(map[Getter]struct{})
+
+	var errs []error
+
+	for _, g := range s.getters {
+		r, ok := g.(taskReleaser)
+		if !ok {
+			continue
+		}
+
+		if _, ok := released[g]; ok {
+			continue
+		}
+		released[g] = struct{}{}
+
+		if err := r.ReleaseTask(taskID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Schemes) getter(link string) (Getter, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	g, ok := s.getters[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedScheme, u.Scheme)
+	}
+
+	return g, nil
+}
+
+// Extensions returns the file extensions an object URL may have:
+// the extension of the path, or of the media type for a data URL.
+func Extensions(u *url.URL) []string {
+	if strings.EqualFold(u.Scheme, "data") {
+		mediaType, _, err := parseDataURL(u.String())
+		if err != nil {
+			return nil
+		}
+
+		exts, _ := mime.ExtensionsByType(mediaType)
+		return exts
+	}
+
+	return []string{path.Ext(u.Path)}
+}
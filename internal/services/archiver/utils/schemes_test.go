@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/stretchr/testify/require"
+)
+
+type stubGetter struct {
+	name string
+}
+
+func (g *stubGetter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	return &object_storage.ArchiveObject{Name: g.name, URL: link}, nil
+}
+
+func (g *stubGetter) Validate(u *url.URL) error {
+	if u.Host == "" {
+		return ErrInvalidURL
+	}
+
+	return nil
+}
+
+func TestSchemes(t *testing.T) {
+	schemes := NewSchemes()
+	schemes.Register(&stubGetter{name: "http"}, "http", "https")
+	schemes.Register(NewDataGetter(Config{}), "data")
+
+	obj, err := schemes.ToLink(context.Background(), "HTTPS://example.com/a.pdf", object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, "http", obj.Name)
+
+	require.NoError(t, schemes.Validate("http://example.com/a.pdf"))
+	require.ErrorIs(t, schemes.Validate("http:///a.pdf"), ErrInvalidURL)
+	require.ErrorIs(t, schemes.Validate("gopher://example.com/a.pdf"), ErrUnsupportedScheme)
+	require.ErrorIs(t, schemes.Validate("data:text/plain"), ErrInvalidURL)
+
+	_, err = schemes.ToLink(context.Background(), "gopher://example.com/a.pdf", object_storage.SourceAuth{})
+	require.ErrorIs(t, err, ErrUnsupportedScheme)
+
+	// The stub doesn't limit the hosts
+	release, err := schemes.AcquireHost(context.Background(), "http://example.com/a.pdf")
+	require.NoError(t, err)
+	release()
+}
+
+func TestDataGetter(t *testing.T) {
+	getter := NewDataGetter(Config{})
+
+	tests := []struct {
+		name        string
+		link        string
+		contentType string
+		content     string
+		ext         string
+	}{
+		{"base64", "data:image/png;base64,iVBORw0KGgo=", "image/png", "\x89PNG\r\n\x1a\n", ".png"},
+		{"base64 without padding", "data:application/pdf;BASE64,JVBERi0", "application/pdf", "%PDF-", ".pdf"},
+		{"percent-encoded", "data:text/plain;charset=utf-8,hello%20world", "text/plain; charset=utf-8", "hello world", ".txt"},
+		{"defaults", "data:,hello", "text/plain; charset=US-ASCII", "hello", ".txt"},
+		{"charset only", "data:;charset=utf-8,hello", "text/plain; charset=utf-8", "hello", ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := getter.ToLink(context.Background(), tt.link, object_storage.SourceAuth{})
+			require.NoError(t, err)
+			require.Equal(t, tt.contentType, obj.ContentType)
+			require.Equal(t, int64(len(tt.content)), obj.Size)
+			require.Equal(t, tt.ext, obj.Name[len(obj.Name)-len(tt.ext):])
+			require.Equal(t, tt.content, readContent(t, obj))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, link := range []string{
+			"data:image/png;base64",
+			"data:image/png;base64,!!!",
+			"data:image/png,%zz",
+			"data:/,x",
+		} {
+			_, err := getter.ToLink(context.Background(), link, object_storage.SourceAuth{})
+			require.ErrorIs(t, err, ErrInvalidURL, link)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		getter := NewDataGetter(Config{ValidContentTypes: []string{"image/png"}, MaxObjectSize: 4})
+
+		u, _ := url.Parse("data:image/png;base64,aGVsbG8=")
+		require.ErrorIs(t, getter.Validate(u), ErrFileTooLarge)
+
+		_, err := getter.ToLink(context.Background(), "data:text/plain,abc", object_storage.SourceAuth{})
+		require.True(t, errors.Is(err, ErrIncorrectFormat))
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	u, _ := url.Parse("data:application/pdf;base64,aGVsbG8=")
+	require.Contains(t, Extensions(u), ".pdf")
+
+	u, _ = url.Parse("s3://bucket/dir/report.pdf?versionId=1")
+	require.Equal(t, []string{".pdf"}, Extensions(u))
+}
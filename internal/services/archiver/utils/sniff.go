@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffSize is how many first bytes of the content are read to detect its type, the read limit of mimetype
+const sniffSize = 3072
+
+// sniff replaces the ContentType of the object with the type detected from the first bytes of the content
+// and checks it, see detectContentType. The read bytes are given back to the reader of the content.
+// Return error:
+//   - ErrIncorrectFormat
+//   - ErrFileTooLarge
+//   - the error of reading the content
+func (l objectLimits) sniff(obj *object_storage.ArchiveObject) error {
+	head, content, err := readHead(obj.Content)
+	if err != nil {
+		return err
+	}
+
+	obj.Content = struct {
+		io.Reader
+		io.Closer
+	}{content, obj.Content}
+	obj.ContentType = detectContentType(head, obj.ContentType)
+
+	return l.checkType(obj.ContentType)
+}
+
+// readHead reads the first sniffSize bytes of r, the returned reader yields the whole content again.
+func readHead(r io.Reader) ([]byte, io.Reader, error) {
+	head := make([]byte, sniffSize)
+
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+
+	head = head[:n]
+
+	return head, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// detectContentType returns the type of the content detected from its first bytes.
+// The type declared by the source is kept only if it refines the detected one:
+// its subtype the detector can't tell apart (text/csv of text/plain), or a text type unknown
+// to the detector for plain text (text/markdown). Unrecognised content is application/octet-stream
+// whatever the source declares, so a made-up type can't pass the valid content types.
+func detectContentType(head []byte, declared string) string {
+	detected := mimetype.Detect(head)
+
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil {
+		return detected.String()
+	}
+
+	// The root of the detector tree, the content is not recognised
+	unknownContent := detected.Parent() == nil
+
+	if known := mimetype.Lookup(mediaType); known != nil {
+		// The parameters of the source are kept, e.g. the charset
+		if detected.Is(mediaType) {
+			return declared
+		}
+
+		if unknownContent {
+			return detected.String()
+		}
+
+		for m := known.Parent(); m != nil; m = m.Parent() {
+			if m.Is(detected.String()) {
+				return declared
+			}
+		}
+
+		return detected.String()
+	}
+
+	if detected.Is("text/plain") && strings.HasPrefix(mediaType, "text/") {
+		return declared
+	}
+
+	return detected.String()
+}
+
+// matchContentType reports whether the content type is one of the valid media types,
+// the valid type may be a wildcard: "image/*" or "*/*".
+func matchContentType(contentType string, valid []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	known := mimetype.Lookup(mediaType)
+
+	for _, v := range valid {
+		switch {
+		case v == "*/*", v == mediaType:
+			return true
+		case strings.HasSuffix(v, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(v, "*")):
+			return true
+		// The aliases of the type, e.g. application/x-zip-compressed for application/zip
+		case known != nil && known.Is(v):
+			return true
+		}
+	}
+
+	return false
+}
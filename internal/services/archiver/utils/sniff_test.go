@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pngHead = "\x89PNG\r\n\x1a\n"
+	pdfHead = "%PDF-1.4\n"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		declared string
+		want     string
+	}{
+		{"no declared type", pngHead, "", "image/png"},
+		{"declared type lies", "<html><body>hi</body></html>", "image/jpeg", "text/html; charset=utf-8"},
+		{"generic declared type", pdfHead, "application/octet-stream", "application/pdf"},
+		{"parameters are kept", pdfHead, "application/pdf; name=a.pdf", "application/pdf; name=a.pdf"},
+		{"alias", pdfHead, "application/x-pdf", "application/x-pdf"},
+		{"subtype of the detected type", "a,b\n1,2\n", "text/csv", "text/csv"},
+		{"known type not detected", "\x00\x01\x02\x03", "image/png", "application/octet-stream"},
+		{"unknown type of binary content", "\x00\x01\x02\x03", "application/x-custom", "application/octet-stream"},
+		{"unknown image type of binary content", "\x00\x01\x02\x03", "image/x-foo", "application/octet-stream"},
+		{"unknown text type", "# title\n", "text/markdown", "text/markdown"},
+		{"unknown type of recognised content", pngHead, "application/x-custom", "image/png"},
+		{"invalid declared type", pngHead, "image/", "image/png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, detectContentType([]byte(tt.content), tt.declared))
+		})
+	}
+}
+
+func TestMatchContentType(t *testing.T) {
+	limits := newObjectLimits(Config{ValidContentTypes: []string{"Image/*", "application/PDF", "application/zip"}})
+
+	for _, contentType := range []string{
+		"image/png",
+		"image/jpeg; charset=binary",
+		"application/pdf",
+		"Application/Pdf; charset=binary",
+		"application/x-zip-compressed",
+	} {
+		require.NoError(t, limits.checkType(contentType), contentType)
+	}
+
+	for _, contentType := range []string{"", "text/plain", "imagex/png", "application/pdfx", "image"} {
+		require.ErrorIs(t, limits.checkType(contentType), ErrIncorrectFormat, contentType)
+	}
+
+	all := newObjectLimits(Config{ValidContentTypes: []string{"*/*"}})
+	require.NoError(t, all.checkType("application/octet-stream"))
+}
+
+func TestToLink_Sniffing(t *testing.T) {
+	content := map[string]string{
+		"/lie.jpg":     "<html><body>not an image</body></html>",
+		"/generic.pdf": pdfHead + strings.Repeat("x", sniffSize),
+		"/params.png":  pngHead,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lie.jpg":
+			w.Header().Set("Content-Type", "image/jpeg")
+		case "/generic.pdf":
+			w.Header().Set("Content-Type", "application/octet-stream")
+		case "/params.png":
+			w.Header().Set("Content-Type", "image/png; charset=binary")
+		}
+
+		io.WriteString(w, content[r.URL.Path])
+	}))
+	defer server.Close()
+
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{ValidContentTypes: []string{"image/*", "application/pdf"}})
+
+	t.Run("lying server", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), server.URL+"/lie.jpg", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrIncorrectFormat)
+	})
+
+	t.Run("generic type", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), server.URL+"/generic.pdf", object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "application/pdf", obj.ContentType)
+		// The bytes read to detect the type are not lost
+		require.Equal(t, content["/generic.pdf"], readContent(t, obj))
+	})
+
+	t.Run("media type parameters", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), server.URL+"/params.png", object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "image/png; charset=binary", obj.ContentType)
+		require.Equal(t, pngHead, readContent(t, obj))
+	})
+}
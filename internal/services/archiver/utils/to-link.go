@@ -1,14 +1,18 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"path"
 	"time"
 
 	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
 )
 
 var (
@@ -18,102 +22,235 @@ var (
 	ErrAuthenticationRequired = errors.New("authentication required")
 	ErrAccessDenied           = errors.New("access denied")
 	ErrInternalSourceError    = errors.New("internal source error")
+	ErrFileTooLarge           = errors.New("file too large")
 )
 
 type ArchiveObjectGetter struct {
-	client            *http.Client
-	validContentTypes map[string]struct{}
+	client      *http.Client
+	limits      objectLimits
+	retry       RetryPolicy
+	domains     *domain_policy.Policy
+	credentials *credentials.Store
+	hosts       *hostLimiter
 }
 
-func NewArchiveObjectGetter(client *http.Client, validContentTypes []string) *ArchiveObjectGetter {
-	var m map[string]struct{}
+type Config struct {
+	// ValidContentTypes are checked against the type detected from the content, "image/*" is a wildcard.
+	ValidContentTypes []string
 
-	if validContentTypes != nil && len(validContentTypes) > 0 {
-		m = make(map[string]struct{}, len(validContentTypes))
+	// MaxObjectSize limits the size of one object in bytes, 0 - no limit.
+	MaxObjectSize int64
 
-		for _, contentType := range validContentTypes {
-			m[contentType] = struct{}{}
-		}
+	Retry RetryPolicy
+
+	// Domains are checked for the URL and every redirect, nil - any domain.
+	Domains *domain_policy.Policy
+
+	Hosts HostLimits
+
+	// Credentials are the named credentials the objects can refer to, nil - none.
+	Credentials *credentials.Store
+}
+
+func NewArchiveObjectGetter(client *http.Client, cfg Config) *ArchiveObjectGetter {
+	if cfg.Domains != nil {
+		client = checkRedirectDomains(client, cfg.Domains)
 	}
 
+	client = withAuthTransport(client, cfg.Credentials)
+
 	return &ArchiveObjectGetter{
-		client:            client,
-		validContentTypes: m,
+		client:      client,
+		limits:      newObjectLimits(cfg),
+		retry:       cfg.Retry,
+		domains:     cfg.Domains,
+		credentials: cfg.Credentials,
+		hosts:       newHostLimiter(cfg.Hosts),
+	}
+}
+
+// checkRedirectDomains returns a copy of the client that checks the domain of every redirect.
+func checkRedirectDomains(client *http.Client, domains *domain_policy.Policy) *http.Client {
+	c := *client
+	next := client.CheckRedirect
+
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := domains.Check(req.URL.Hostname()); err != nil {
+			return err
+		}
+
+		if next != nil {
+			return next(req, via)
+		}
+
+		// The default policy of http.Client
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		return nil
 	}
+
+	return &c
 }
 
-func (a *ArchiveObjectGetter) ToLink(link string) (*object_storage.ArchiveObject, error) {
-	req, err := http.NewRequest(http.MethodGet, link, nil)
+// Validate rejects the URLs without a host.
+func (a *ArchiveObjectGetter) Validate(u *url.URL) error {
+	if u.Host == "" {
+		return fmt.Errorf("%w: no host", ErrInvalidURL)
+	}
+
+	return nil
+}
+
+// AcquireHost takes one of the HostLimits.MaxConns slots of the host of link,
+// release frees it after the object is downloaded.
+// The slots are not taken by ToLink: the archiver writes the objects of a task in order,
+// so it takes them in order too, otherwise a later object could hold the last slot
+// the earlier one is waiting for.
+func (a *ArchiveObjectGetter) AcquireHost(ctx context.Context, link string) (release func(), err error) {
+	return a.hosts.acquire(ctx, link)
+}
+
+// ToLink opens the object, transient failures are retried according to the RetryPolicy.
+// Only opening is retried, it includes reading the first bytes to detect the content type:
+// once the body is returned, read errors are final.
+// On failure the error is an *AttemptError.
+func (a *ArchiveObjectGetter) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	if err := a.domains.CheckURL(link); err != nil {
+		return nil, &AttemptError{Err: err, attempts: 1}
+	}
+
+	// The credential may be gone from the config after a restart
+	if auth.Credential != "" {
+		if err := a.credentials.Check(auth.Credential, link); err != nil {
+			return nil, &AttemptError{Err: err, attempts: 1}
+		}
+	}
+
+	ctx = withAuth(ctx, link, auth)
+
+	for attempt := 1; ; attempt++ {
+		obj, err := a.open(ctx, link)
+		if err == nil {
+			obj.Attempts = attempt
+			return obj, nil
+		}
+
+		delay, ok := a.retry.backoff(attempt, err)
+		if !ok || ctx.Err() != nil {
+			return nil, &AttemptError{Err: err, attempts: attempt}
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, &AttemptError{Err: err, attempts: attempt}
+		}
+	}
+}
+
+func (a *ArchiveObjectGetter) open(ctx context.Context, link string) (*object_storage.ArchiveObject, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
 	if err != nil {
 		return nil, fmt.Errorf("new request failed: %w", err) // TODO
 	}
 
-	req.Close = true
+	if err := a.hosts.wait(ctx, link); err != nil {
+		return nil, err
+	}
 
 	resp, err := a.client.Do(req)
-	if resp != nil {
-		defer resp.Body.Close()
+	if err != nil {
+		err = fmt.Errorf("request failed: %w", err)
+		if ctx.Err() != nil || errors.Is(err, safe_dialer.ErrAddressBlocked) || errors.Is(err, domain_policy.ErrDomainNotAllowed) {
+			return nil, err
+		}
+
+		return nil, &transientError{err: err}
 	}
 
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w, code: %d", err, resp.StatusCode)
+	if err := a.checkResponse(resp); err != nil {
+		resp.Body.Close()
+
+		if isTransientStatus(resp.StatusCode) {
+			return nil, &transientError{
+				err:        err,
+				retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			}
+		}
+
+		return nil, err
+	}
+
+	finalURL := resp.Request.URL.String()
+
+	filename := path.Base(finalURL)
+	if filename == "" || filename == "." || filename == "/" {
+		filename = "file_" + time.Now().Format("20060102150405")
+	}
+
+	obj := &object_storage.ArchiveObject{
+		Name:        filename,
+		Time:        time.Now(),
+		Size:        resp.ContentLength,
+		Content:     a.limits.limit(resp.Body),
+		URL:         finalURL,
+		ContentType: resp.Header.Get("Content-Type"),
 	}
 
+	if err := a.limits.sniff(obj); err != nil {
+		resp.Body.Close()
+
+		if errors.Is(err, ErrIncorrectFormat) || errors.Is(err, ErrFileTooLarge) || ctx.Err() != nil {
+			return nil, err
+		}
+
+		// The body broke before the object was returned, opening is retried
+		return nil, &transientError{err: fmt.Errorf("read failed: %w", err)}
+	}
+
+	return obj, nil
+}
+
+func (a *ArchiveObjectGetter) checkResponse(resp *http.Response) error {
 	switch resp.StatusCode {
 	case http.StatusNotFound:
-		return nil, ErrFileNotFound
+		return ErrFileNotFound
 
 	case http.StatusBadRequest:
-		return nil, ErrBadRequest
+		return ErrBadRequest
 
 	case http.StatusUnauthorized:
-		return nil, ErrAuthenticationRequired
+		return ErrAuthenticationRequired
 
 	case http.StatusForbidden:
-		return nil, ErrAccessDenied
+		return ErrAccessDenied
 
 	case http.StatusTooManyRequests:
-		return nil, ErrBadRequest
+		return ErrBadRequest
 
 	case http.StatusInternalServerError,
 		http.StatusBadGateway,
 		http.StatusServiceUnavailable,
 		http.StatusGatewayTimeout:
-		return nil, ErrInternalSourceError
+		return ErrInternalSourceError
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
-	if a.validContentTypes != nil {
-		if _, ok := a.validContentTypes[contentType]; !ok {
-			return nil, fmt.Errorf("%w: %s", ErrIncorrectFormat, contentType)
-		}
+	// Redirects are not followed with http.max_redirects -1
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		return fmt.Errorf("%w: redirect %d not followed", ErrBadRequest, resp.StatusCode)
 	}
 
-	finalURL := resp.Request.URL.String()
-
-	filename := path.Base(finalURL)
-	if filename == "" || filename == "." || filename == "/" {
-		filename = "file_" + time.Now().Format("20060102150405")
-	}
-
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read response failed: %w", err)
-	}
-
-	return &object_storage.ArchiveObject{
-		Name:    filename,
-		Time:    time.Now(),
-		Content: content,
-	}, nil
+	return a.limits.check(resp.ContentLength)
 }
 
-func validateContentType(contentType string, validContentTypes []string) bool {
-	for _, validContentType := range validContentTypes {
-		if contentType == validContentType {
-			return true
-		}
+func isTransientStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
 	}
 
 	return false
@@ -1,13 +1,21 @@
 package utils
 
 import (
+	"context"
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	"github.com/fandasy/06.08.2025/internal/pkg/credentials"
+	domain_policy "github.com/fandasy/06.08.2025/internal/pkg/domain-policy"
+	safe_dialer "github.com/fandasy/06.08.2025/internal/pkg/safe-dialer"
 	"github.com/stretchr/testify/require"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestToLink_ValidRequests(t *testing.T) {
@@ -21,7 +29,7 @@ func TestToLink_ValidRequests(t *testing.T) {
 	serverRedirectTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "image/jpeg")
 		w.WriteHeader(http.StatusOK)
-		io.WriteString(w, "fake jpeg content")
+		io.WriteString(w, "\xff\xd8\xff\xe0 fake jpeg content")
 	}))
 	defer serverRedirectTarget.Close()
 
@@ -39,26 +47,379 @@ func TestToLink_ValidRequests(t *testing.T) {
 	defer serverNoName.Close()
 
 	validTypes := []string{"application/pdf", "image/jpeg"}
-	getter := NewArchiveObjectGetter(http.DefaultClient, validTypes)
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{ValidContentTypes: validTypes})
 
 	t.Run("simple pdf download", func(t *testing.T) {
-		obj, err := getter.ToLink(serverPDF.URL + "/test.pdf")
+		obj, err := getter.ToLink(context.Background(), serverPDF.URL+"/test.pdf", object_storage.SourceAuth{})
 		require.NoError(t, err)
 		require.Equal(t, ".pdf", filepath.Ext(obj.Name))
-		require.Contains(t, string(obj.Content), "fake pdf content")
+		require.Contains(t, readContent(t, obj), "fake pdf content")
 	})
 
 	t.Run("redirect to jpeg", func(t *testing.T) {
-		obj, err := getter.ToLink(serverRedirect.URL + "/redir")
+		obj, err := getter.ToLink(context.Background(), serverRedirect.URL+"/redir", object_storage.SourceAuth{})
 		require.NoError(t, err)
 		require.Equal(t, ".jpg", filepath.Ext(obj.Name))
-		require.Contains(t, string(obj.Content), "jpeg content")
+		require.Equal(t, serverRedirectTarget.URL+"/image.jpg", obj.URL)
+		require.Equal(t, "image/jpeg", obj.ContentType)
+		require.Contains(t, readContent(t, obj), "jpeg content")
 	})
 
 	t.Run("no filename in URL", func(t *testing.T) {
-		obj, err := getter.ToLink(serverNoName.URL + "/.")
+		obj, err := getter.ToLink(context.Background(), serverNoName.URL+"/.", object_storage.SourceAuth{})
 		require.NoError(t, err)
 		require.True(t, strings.HasPrefix(obj.Name, "file_"), "expected autogenerated filename")
-		require.Contains(t, string(obj.Content), "content no name")
+		require.Contains(t, readContent(t, obj), "content no name")
 	})
 }
+
+func readContent(t *testing.T, obj *object_storage.ArchiveObject) string {
+	t.Helper()
+	defer obj.Content.Close()
+
+	content, err := io.ReadAll(obj.Content)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestToLink_MaxObjectSize(t *testing.T) {
+	// Over the bytes read to detect the content type
+	const maxSize = sniffSize + 16
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := strings.Repeat("x", maxSize+1)
+		if r.URL.Path == "/exact.pdf" {
+			body = strings.Repeat("x", maxSize)
+		}
+
+		if r.URL.Path == "/chunked.pdf" {
+			// No Content-Length, the limit is enforced while reading
+			w.(http.Flusher).Flush()
+		} else {
+			// The body is too long to get it implicitly
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{MaxObjectSize: maxSize})
+
+	t.Run("content-length over the limit", func(t *testing.T) {
+		_, err := getter.ToLink(context.Background(), server.URL+"/big.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileTooLarge)
+	})
+
+	t.Run("chunked over the limit", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), server.URL+"/chunked.pdf", object_storage.SourceAuth{})
+		require.NoError(t, err)
+		defer obj.Content.Close()
+
+		content, err := io.ReadAll(obj.Content)
+		require.ErrorIs(t, err, ErrFileTooLarge)
+		require.Len(t, content, maxSize)
+	})
+
+	t.Run("exactly at the limit", func(t *testing.T) {
+		obj, err := getter.ToLink(context.Background(), server.URL+"/exact.pdf", object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Len(t, readContent(t, obj), maxSize)
+	})
+}
+
+func TestToLink_Retry(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+
+		switch r.URL.Path {
+		case "/flaky.pdf":
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			io.WriteString(w, "content")
+
+		case "/limited.pdf":
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+		case "/slow-down.pdf":
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+
+		case "/missing.pdf":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{
+		Retry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     2 * time.Second,
+		},
+	})
+
+	attempts := func(t *testing.T, err error) int {
+		var ae *AttemptError
+		require.ErrorAs(t, err, &ae)
+		return ae.Attempts()
+	}
+
+	t.Run("transient failures are retried", func(t *testing.T) {
+		calls.Store(0)
+
+		obj, err := getter.ToLink(context.Background(), server.URL+"/flaky.pdf", object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, 3, obj.Attempts)
+		require.Equal(t, "content", readContent(t, obj))
+	})
+
+	t.Run("retry-after is respected", func(t *testing.T) {
+		calls.Store(0)
+
+		start := time.Now()
+		_, err := getter.ToLink(context.Background(), server.URL+"/limited.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrBadRequest)
+		require.Equal(t, 3, attempts(t, err))
+		require.GreaterOrEqual(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("retry-after over max backoff is not waited for", func(t *testing.T) {
+		calls.Store(0)
+
+		_, err := getter.ToLink(context.Background(), server.URL+"/slow-down.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrBadRequest)
+		require.Equal(t, 1, attempts(t, err))
+	})
+
+	t.Run("permanent failures are not retried", func(t *testing.T) {
+		calls.Store(0)
+
+		_, err := getter.ToLink(context.Background(), server.URL+"/missing.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileNotFound)
+		require.Equal(t, 1, attempts(t, err))
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("network errors are retried", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		_, err := getter.ToLink(context.Background(), closed.URL+"/file.pdf", object_storage.SourceAuth{})
+		require.Error(t, err)
+		require.Equal(t, 3, attempts(t, err))
+	})
+
+	t.Run("blocked addresses are not retried", func(t *testing.T) {
+		calls.Store(0)
+
+		dialer, err := safe_dialer.New(safe_dialer.Config{})
+		require.NoError(t, err)
+
+		getter := NewArchiveObjectGetter(dialer.Client(), Config{
+			Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+		})
+
+		_, err = getter.ToLink(context.Background(), server.URL+"/flaky.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, safe_dialer.ErrAddressBlocked)
+		require.Equal(t, 1, attempts(t, err))
+		require.Zero(t, calls.Load())
+	})
+
+	t.Run("context cancellation stops retries", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := getter.ToLink(ctx, server.URL+"/limited.pdf", object_storage.SourceAuth{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestToLink_Domains(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		if r.URL.Path == "/redirect.pdf" {
+			http.Redirect(w, r, "http://"+strings.Replace(r.Host, "127.0.0.1", "localhost", 1)+"/file.pdf", http.StatusFound)
+			return
+		}
+		io.WriteString(w, "content")
+	}))
+	defer server.Close()
+
+	domains, err := domain_policy.New([]string{"127.0.0.1"}, nil)
+	require.NoError(t, err)
+
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{
+		Domains: domains,
+		Retry:   RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+
+	obj, err := getter.ToLink(context.Background(), server.URL+"/file.pdf", object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, "content", readContent(t, obj))
+
+	// Every redirect is checked, the blocked one is not retried
+	calls.Store(0)
+	_, err = getter.ToLink(context.Background(), server.URL+"/redirect.pdf", object_storage.SourceAuth{})
+	require.ErrorIs(t, err, domain_policy.ErrDomainNotAllowed)
+	require.EqualValues(t, 1, calls.Load())
+
+	calls.Store(0)
+	_, err = getter.ToLink(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1)+"/file.pdf", object_storage.SourceAuth{})
+	require.ErrorIs(t, err, domain_policy.ErrDomainNotAllowed)
+	require.Zero(t, calls.Load())
+}
+
+func TestToLink_Auth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect.pdf" {
+			http.Redirect(w, r, "http://"+strings.Replace(r.Host, "127.0.0.1", "localhost", 1)+"/file.pdf", http.StatusFound)
+			return
+		}
+		io.WriteString(w, r.Header.Get("X-Token")+"|"+r.Header.Get("X-Api-Key"))
+	}))
+	defer server.Close()
+
+	creds, err := credentials.New(map[string]credentials.Credential{
+		"cdn": {Domains: []string{"127.0.0.1"}, Headers: map[string]string{"X-Api-Key": "key"}},
+	})
+	require.NoError(t, err)
+
+	getter := NewArchiveObjectGetter(http.DefaultClient, Config{Credentials: creds})
+
+	auth := object_storage.SourceAuth{Headers: map[string]string{"X-Token": "token"}, Credential: "cdn"}
+
+	obj, err := getter.ToLink(context.Background(), server.URL+"/file.pdf", auth)
+	require.NoError(t, err)
+	require.Equal(t, "token|key", readContent(t, obj))
+
+	// Neither is sent to another host after a redirect
+	obj, err = getter.ToLink(context.Background(), server.URL+"/redirect.pdf", auth)
+	require.NoError(t, err)
+	require.Equal(t, "|", readContent(t, obj))
+
+	// Without auth nothing is sent
+	obj, err = getter.ToLink(context.Background(), server.URL+"/file.pdf", object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, "|", readContent(t, obj))
+
+	_, err = getter.ToLink(context.Background(), server.URL+"/file.pdf", object_storage.SourceAuth{Credential: "other"})
+	require.ErrorIs(t, err, credentials.ErrUnknownCredential)
+}
+
+type headersRecorder struct {
+	header http.Header
+}
+
+func (h *headersRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.header = req.Header
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestAuthTransport_NoDowngrade(t *testing.T) {
+	creds, err := credentials.New(map[string]credentials.Credential{
+		"cdn": {Domains: []string{"files.example"}, Headers: map[string]string{"X-Api-Key": "key"}},
+	})
+	require.NoError(t, err)
+
+	next := &headersRecorder{}
+	transport := &authTransport{next: next, credentials: creds}
+
+	auth := object_storage.SourceAuth{Headers: map[string]string{"Authorization": "Bearer token"}, Credential: "cdn"}
+	ctx := withAuth(context.Background(), "https://files.example/file.pdf", auth)
+
+	roundTrip := func(link string) http.Header {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+		require.NoError(t, err)
+
+		_, err = transport.RoundTrip(req)
+		require.NoError(t, err)
+
+		return next.header
+	}
+
+	header := roundTrip("https://files.example/file.pdf")
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Equal(t, "key", header.Get("X-Api-Key"))
+
+	// A redirect to http on the same host gets neither
+	header = roundTrip("http://files.example/file.pdf")
+	require.Empty(t, header.Get("Authorization"))
+	require.Empty(t, header.Get("X-Api-Key"))
+}
+
+func TestToLink_RedirectNotFollowed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file.pdf", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	// The redirect page is not archived as the object
+	_, err := NewArchiveObjectGetter(client, Config{}).ToLink(context.Background(), server.URL+"/old.pdf", object_storage.SourceAuth{})
+	require.ErrorIs(t, err, ErrBadRequest)
+}
+
+func TestHostLimiter(t *testing.T) {
+	l := newHostLimiter(HostLimits{MaxConns: 1, RequestsPerSecond: 20})
+
+	release, err := l.acquire(context.Background(), "http://a.example/1")
+	require.NoError(t, err)
+
+	// Other hosts have their own slots
+	releaseOther, err := l.acquire(context.Background(), "http://b.example/1")
+	require.NoError(t, err)
+	releaseOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx, "http://A.example/2")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release()
+
+	// Sources without a network host are not limited
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	for _, link := range []string{"data:,x", "data:,y", "upload://task/task.1/a.pdf", "upload://task/task.2/b.pdf"} {
+		_, err = l.acquire(ctx, link)
+		require.NoError(t, err, link)
+	}
+
+	release, err = l.acquire(context.Background(), "http://a.example/2")
+	require.NoError(t, err)
+	release()
+
+	// The first request goes at once, the next ones are spaced by 50ms
+	start := time.Now()
+	for range 3 {
+		require.NoError(t, l.wait(context.Background(), "http://c.example/file"))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 8, 6, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, 5*time.Second, parseRetryAfter("5", now))
+	require.Equal(t, time.Minute, parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	require.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+	require.Zero(t, parseRetryAfter("-1", now))
+	require.Zero(t, parseRetryAfter("soon", now))
+	require.Zero(t, parseRetryAfter("", now))
+}
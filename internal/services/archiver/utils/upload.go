@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+)
+
+// UploadScheme is the scheme of the links to the uploaded files:
+// upload://<task id>/<object name>/<file name>?type=<content type>
+const UploadScheme = "upload"
+
+// Uploads keeps the files uploaded into the tasks until the tasks are finished.
+// The files are saved to an object storage, not kept in memory, as <task id>.<random id>.
+// It is registered with Schemes.RegisterInternal: the links are made by Save only.
+type Uploads struct {
+	storage object_storage.ObjectStorage
+	limits  objectLimits
+}
+
+func NewUploads(storage object_storage.ObjectStorage, cfg Config) *Uploads {
+	return &Uploads{storage: storage, limits: newObjectLimits(cfg)}
+}
+
+// Save stores the file and returns its link, a file over the size limit is not kept.
+// The content type is detected from the content, contentType is only a hint, see sniff.
+// Return error:
+//   - ErrIncorrectFormat
+//   - ErrFileTooLarge
+func (u *Uploads) Save(ctx context.Context, taskID, fileName, contentType string, content io.Reader) (string, error) {
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(fileName))
+	}
+
+	// The type is detected before anything is written, the declared one is not trusted
+	head, content, err := readHead(content)
+	if err != nil {
+		return "", err
+	}
+
+	contentType = detectContentType(head, contentType)
+
+	if err := u.limits.checkType(contentType); err != nil {
+		return "", err
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	name := taskID + "." + hex.EncodeToString(id)
+
+	limited := u.limits.limit(io.NopCloser(content))
+
+	if _, err := u.storage.Save(ctx, name, limited); err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			return "", ErrFileTooLarge
+		}
+
+		return "", err
+	}
+
+	link := url.URL{
+		Scheme:   UploadScheme,
+		Host:     taskID,
+		Path:     "/" + name + "/" + fileName,
+		RawQuery: url.Values{"type": {contentType}}.Encode(),
+	}
+
+	return link.String(), nil
+}
+
+// Remove deletes the uploaded file of the link.
+func (u *Uploads) Remove(link string) error {
+	_, name, _, _, err := parseUploadLink(link)
+	if err != nil {
+		return err
+	}
+
+	return u.storage.Delete(context.Background(), name)
+}
+
+// IsUploadLink reports whether the link is a link to an uploaded file, see Save.
+func IsUploadLink(link string) bool {
+	scheme, _, ok := strings.Cut(link, ":")
+
+	return ok && strings.EqualFold(scheme, UploadScheme)
+}
+
+// ReleaseTask deletes the uploaded files of the task, it is called when the task is finished.
+func (u *Uploads) ReleaseTask(taskID string) error {
+	objs, err := u.storage.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, obj := range objs {
+		if strings.HasPrefix(obj.Name, taskID+".") {
+			if err := u.storage.Delete(context.Background(), obj.Name); err != nil && !errors.Is(err, object_storage.ErrObjectNotFound) {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Clear deletes all uploaded files, it is used when the tasks are not kept across restarts.
+func (u *Uploads) Clear() error {
+	objs, err := u.storage.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, obj := range objs {
+		if err := u.storage.Delete(context.Background(), obj.Name); err != nil && !errors.Is(err, object_storage.ErrObjectNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (u *Uploads) ToLink(ctx context.Context, link string, auth object_storage.SourceAuth) (*object_storage.ArchiveObject, error) {
+	_, name, fileName, contentType, err := parseUploadLink(link)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := u.storage.Open(ctx, name)
+	if err != nil {
+		if errors.Is(err, object_storage.ErrObjectNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrFileNotFound, err)
+		}
+
+		return nil, err
+	}
+
+	return &object_storage.ArchiveObject{
+		Name:        fileName,
+		Time:        obj.ModTime,
+		Size:        obj.Size,
+		Content:     obj.Content,
+		URL:         link,
+		ContentType: contentType,
+	}, nil
+}
+
+// parseUploadLink returns the parts of a link made by Save.
+func parseUploadLink(link string) (taskID, name, fileName, contentType string, err error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", "", "", "", fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	name, fileName, ok := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if u.Scheme != UploadScheme || !ok || !strings.HasPrefix(name, u.Host+".") || fileName == "" {
+		return "", "", "", "", fmt.Errorf("%w: not an upload link", ErrInvalidURL)
+	}
+
+	if err := object_storage.ValidateName(name); err != nil {
+		return "", "", "", "", fmt.Errorf("%w: %s", ErrInvalidURL, err)
+	}
+
+	return u.Host, name, fileName, u.Query().Get("type"), nil
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	object_storage "github.com/fandasy/06.08.2025/internal/object-storage"
+	memory_storage "github.com/fandasy/06.08.2025/internal/object-storage/memory-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploads(t *testing.T) {
+	storage := memory_storage.New()
+	uploads := NewUploads(storage, Config{MaxObjectSize: 32, ValidContentTypes: []string{"text/plain", "application/pdf"}})
+
+	t.Run("save and get", func(t *testing.T) {
+		link, err := uploads.Save(context.Background(), "task1", "a.pdf", "", strings.NewReader("%PDF-1.4 pdf content"))
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(link, "upload://task1/task1."), link)
+
+		obj, err := uploads.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "a.pdf", obj.Name)
+		require.Equal(t, "application/pdf", obj.ContentType)
+		require.Equal(t, int64(len("%PDF-1.4 pdf content")), obj.Size)
+		require.Equal(t, "%PDF-1.4 pdf content", readContent(t, obj))
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		// The declared type is not trusted
+		_, err := uploads.Save(context.Background(), "task1", "a.pdf", "application/pdf", strings.NewReader("\x89PNG\r\n\x1a\n"))
+		require.ErrorIs(t, err, ErrIncorrectFormat)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		before, err := storage.List(context.Background())
+		require.NoError(t, err)
+
+		_, err = uploads.Save(context.Background(), "task1", "b.txt", "text/plain", strings.NewReader(strings.Repeat("x", 33)))
+		require.ErrorIs(t, err, ErrFileTooLarge)
+
+		after, err := storage.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, after, len(before))
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		link, err := uploads.Save(context.Background(), "task2", "c.txt", "text/plain", strings.NewReader("c"))
+		require.NoError(t, err)
+		require.True(t, IsUploadLink(link))
+		require.False(t, IsUploadLink("https://example.com/c.txt"))
+
+		require.NoError(t, uploads.Remove(link))
+
+		_, err = uploads.ToLink(context.Background(), link, object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileNotFound)
+	})
+
+	t.Run("release task", func(t *testing.T) {
+		link1, err := uploads.Save(context.Background(), "task3", "a.txt", "text/plain", strings.NewReader("a"))
+		require.NoError(t, err)
+		link2, err := uploads.Save(context.Background(), "task4", "a.txt", "text/plain", strings.NewReader("a"))
+		require.NoError(t, err)
+
+		require.NoError(t, uploads.ReleaseTask("task3"))
+
+		_, err = uploads.ToLink(context.Background(), link1, object_storage.SourceAuth{})
+		require.ErrorIs(t, err, ErrFileNotFound)
+
+		obj, err := uploads.ToLink(context.Background(), link2, object_storage.SourceAuth{})
+		require.NoError(t, err)
+		require.Equal(t, "a", readContent(t, obj))
+	})
+
+	t.Run("invalid link", func(t *testing.T) {
+		for _, link := range []string{"upload://task1/task2.00/a.txt", "upload://task1/task1.00", "http://task1/task1.00/a.txt"} {
+			_, err := uploads.ToLink(context.Background(), link, object_storage.SourceAuth{})
+			require.ErrorIs(t, err, ErrInvalidURL, link)
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		require.NoError(t, uploads.Clear())
+
+		objs, err := storage.List(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, objs)
+	})
+}
+
+func TestSchemes_RegisterInternal(t *testing.T) {
+	uploads := NewUploads(memory_storage.New(), Config{})
+
+	schemes := NewSchemes()
+	schemes.RegisterInternal(uploads, UploadScheme)
+
+	link, err := uploads.Save(context.Background(), "task1", "a.txt", "text/plain", strings.NewReader("a"))
+	require.NoError(t, err)
+
+	// The links can't be sent by clients
+	require.ErrorIs(t, schemes.Validate(link), ErrUnsupportedScheme)
+
+	obj, err := schemes.ToLink(context.Background(), link, object_storage.SourceAuth{})
+	require.NoError(t, err)
+	require.Equal(t, "a", readContent(t, obj))
+
+	require.NoError(t, schemes.ReleaseTask("task1"))
+
+	_, err = schemes.ToLink(context.Background(), link, object_storage.SourceAuth{})
+	require.ErrorIs(t, err, ErrFileNotFound)
+}
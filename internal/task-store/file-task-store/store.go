@@ -0,0 +1,282 @@
+package file_task_store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	task_store "github.com/fandasy/06.08.2025/internal/task-store"
+	"github.com/fandasy/06.08.2025/pkg/e"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	journalFile  = "journal.log"
+
+	// compactEvery is the number of journal entries after which
+	// the journal is folded into a new snapshot.
+	compactEvery = 1000
+)
+
+const (
+	opSave   = "save"
+	opDelete = "delete"
+)
+
+var ErrStoreClosed = errors.New("task store closed")
+
+// Store keeps tasks in an append-only journal plus a periodic snapshot:
+//   - every Save/Delete appends one JSON line to the journal
+//   - on start and every compactEvery entries the current state is written
+//     to the snapshot and the journal is truncated
+type Store struct {
+	dir string
+
+	mu      sync.Mutex
+	tasks   map[string]*task_store.Task
+	journal *os.File
+	entries int
+}
+
+type entry struct {
+	Op   string           `json:"op"`
+	ID   string           `json:"id,omitempty"`
+	Task *task_store.Task `json:"task,omitempty"`
+}
+
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0774); err != nil {
+		return nil, e.Wrap("can't create a task store dir", err)
+	}
+
+	s := &Store{
+		dir:   dir,
+		tasks: make(map[string]*task_store.Task),
+	}
+
+	if err := s.readSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := s.replayJournal(); err != nil {
+		return nil, err
+	}
+
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Load returns all tasks restored from disk.
+func (s *Store) Load() ([]*task_store.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]*task_store.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+func (s *Store) Save(t *task_store.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(entry{Op: opSave, Task: t}); err != nil {
+		return err
+	}
+
+	s.tasks[t.ID] = t
+
+	return s.compactIfNeeded()
+}
+
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(entry{Op: opDelete, ID: id}); err != nil {
+		return err
+	}
+
+	delete(s.tasks, id)
+
+	return s.compactIfNeeded()
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return ErrStoreClosed
+	}
+
+	err := s.journal.Close()
+	s.journal = nil
+
+	return e.Wrap("file-task-store.journal.Close", err)
+}
+
+func (s *Store) append(en entry) error {
+	if s.journal == nil {
+		return ErrStoreClosed
+	}
+
+	data, err := json.Marshal(en)
+	if err != nil {
+		return e.Wrap("file-task-store.json.Marshal", err)
+	}
+
+	data = append(data, '\n')
+
+	if _, err := s.journal.Write(data); err != nil {
+		return e.Wrap("file-task-store.journal.Write", err)
+	}
+
+	if err := s.journal.Sync(); err != nil {
+		return e.Wrap("file-task-store.journal.Sync", err)
+	}
+
+	s.entries++
+
+	return nil
+}
+
+func (s *Store) compactIfNeeded() error {
+	if s.entries < compactEvery {
+		return nil
+	}
+
+	return s.compact()
+}
+
+// compact writes the current state into a new snapshot and starts an empty journal.
+// The snapshot is replaced atomically, so a crash leaves either the old
+// snapshot with the full journal or the new snapshot.
+func (s *Store) compact() error {
+	tasks := make([]*task_store.Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		tasks = append(tasks, t)
+	}
+
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		return e.Wrap("file-task-store.json.Marshal", err)
+	}
+
+	tmpPath := filepath.Join(s.dir, snapshotFile+".tmp")
+
+	if err := writeFileSync(tmpPath, data); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return e.Wrap("file-task-store.os.Rename", err)
+	}
+
+	if s.journal != nil {
+		s.journal.Close()
+	}
+
+	journal, err := os.OpenFile(filepath.Join(s.dir, journalFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0664)
+	if err != nil {
+		s.journal = nil
+		return e.Wrap("file-task-store.os.OpenFile", err)
+	}
+
+	s.journal = journal
+	s.entries = 0
+
+	return nil
+}
+
+func (s *Store) readSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return e.Wrap("file-task-store.os.ReadFile", err)
+	}
+
+	var tasks []*task_store.Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return e.Wrap("failed to parse task store snapshot", err)
+	}
+
+	for _, t := range tasks {
+		s.tasks[t.ID] = t
+	}
+
+	return nil
+}
+
+// replayJournal applies the journal on top of the snapshot.
+// A torn last line (crash in the middle of a write) is ignored,
+// a broken line anywhere else is an error.
+func (s *Store) replayJournal() error {
+	file, err := os.Open(filepath.Join(s.dir, journalFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return e.Wrap("file-task-store.os.Open", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var (
+		line    int
+		tornErr error
+	)
+
+	for scanner.Scan() {
+		line++
+
+		if tornErr != nil {
+			return tornErr
+		}
+
+		var en entry
+		if err := json.Unmarshal(scanner.Bytes(), &en); err != nil {
+			tornErr = e.Wrap(fmt.Sprintf("broken task store journal line %d", line), err)
+			continue
+		}
+
+		switch en.Op {
+		case opSave:
+			if en.Task != nil {
+				s.tasks[en.Task.ID] = en.Task
+			}
+		case opDelete:
+			delete(s.tasks, en.ID)
+		}
+	}
+
+	return e.Wrap("file-task-store.scanner.Scan", scanner.Err())
+}
+
+func writeFileSync(name string, data []byte) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return e.Wrap("file-task-store.os.Create", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return e.Wrap("file-task-store.file.Write", err)
+	}
+
+	return e.Wrap("file-task-store.file.Sync", file.Sync())
+}
@@ -0,0 +1,86 @@
+package file_task_store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	task_store "github.com/fandasy/06.08.2025/internal/task-store"
+)
+
+func TestStore_ReloadAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	st, err := New(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, st.Save(&task_store.Task{ID: "a", Status: 1}))
+	require.NoError(t, st.Save(&task_store.Task{ID: "b", Status: 1}))
+	require.NoError(t, st.Save(&task_store.Task{
+		ID:      "a",
+		Status:  3,
+		Objects: []task_store.Object{{Src: "http://x/1.pdf"}, {Src: "http://x/2.pdf", Err: "file not found"}},
+		Zip:     "http://localhost/zips/a",
+	}))
+	require.NoError(t, st.Delete("b"))
+	require.NoError(t, st.Close())
+
+	st, err = New(dir)
+	require.NoError(t, err)
+	defer st.Close()
+
+	tasks, err := st.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	got := tasks[0]
+	require.Equal(t, "a", got.ID)
+	require.Equal(t, int8(3), got.Status)
+	require.Equal(t, "http://localhost/zips/a", got.Zip)
+	require.Len(t, got.Objects, 2)
+	require.Equal(t, "file not found", got.Objects[1].Err)
+}
+
+func TestStore_TornJournalLine(t *testing.T) {
+	dir := t.TempDir()
+
+	st, err := New(dir)
+	require.NoError(t, err)
+	require.NoError(t, st.Save(&task_store.Task{ID: "a", Status: 1}))
+	require.NoError(t, st.Close())
+
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0664)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"save","task":{"id":"b"`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	st, err = New(dir)
+	require.NoError(t, err)
+	defer st.Close()
+
+	tasks, err := st.Load()
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "a", tasks[0].ID)
+}
+
+func TestStore_BrokenJournalLine(t *testing.T) {
+	dir := t.TempDir()
+
+	st, err := New(dir)
+	require.NoError(t, err)
+	require.NoError(t, st.Save(&task_store.Task{ID: "a", Status: 1}))
+	require.NoError(t, st.Close())
+
+	f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0664)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"save","task":{"id":"b"` + "\n" + `{"op":"delete","id":"a"}` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = New(dir)
+	require.Error(t, err)
+}
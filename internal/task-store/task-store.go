@@ -0,0 +1,33 @@
+package task_store
+
+import (
+	"time"
+)
+
+type Task struct {
+	ID           string    `json:"id"`
+	Status       int8      `json:"status"`
+	Objects      []Object  `json:"objects"`
+	Zip          string    `json:"zip,omitempty"`
+	Err          string    `json:"error,omitempty"`
+	ErrCode      string    `json:"error_code,omitempty"`
+	CallbackURL  string    `json:"callback_url,omitempty"`
+	Format       string    `json:"format,omitempty"`
+	Encrypted    bool      `json:"encrypted,omitempty"`
+	Manifest     bool      `json:"manifest,omitempty"`
+	NameConflict string    `json:"name_conflict,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type Object struct {
+	Src  string `json:"src"`
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+	// Credential is the name of the credential from the config
+	Credential string `json:"credential,omitempty"`
+	// Headers is set if the object had request headers, they are not persisted
+	Headers  bool   `json:"headers,omitempty"`
+	Err      string `json:"error,omitempty"`
+	ErrCode  string `json:"error_code,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+}